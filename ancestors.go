@@ -0,0 +1,267 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aarzilli/nucular"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+)
+
+const (
+	ancestorsModeAncestors = iota
+	ancestorsModeChildren
+)
+
+// maxAncestorsChain is the maximum number of ancestors loaded by the
+// Ancestors panel, this is larger than NumAncestors because the panel
+// shows ancestors collapsed by default.
+const maxAncestorsChain = 20
+
+var ancestorsPanel = struct {
+	asyncLoad asyncLoad
+	mode      int
+	depth     int
+	id        int
+
+	gid       int
+	stack     []api.Stackframe
+	ancestors []api.Ancestor
+	children  []*api.Goroutine // goroutines created by gid
+
+	ancestorsUnavailable bool
+	childrenTruncated    int // if not zero only this many goroutines were searched for children
+}{
+	depth: 20,
+}
+
+func init() {
+	ancestorsPanel.asyncLoad.load = loadAncestors
+}
+
+func loadAncestors(p *asyncLoad) {
+	ancestorsPanel.id++
+	ancestorsPanel.gid = curGid
+	ancestorsPanel.ancestors = ancestorsPanel.ancestors[:0]
+	ancestorsPanel.children = ancestorsPanel.children[:0]
+	ancestorsPanel.ancestorsUnavailable = false
+	ancestorsPanel.childrenTruncated = 0
+
+	if curGid < 0 {
+		ancestorsPanel.stack = nil
+		p.done(nil)
+		return
+	}
+
+	var err error
+	ancestorsPanel.stack, err = client.Stacktrace(curGid, ancestorsPanel.depth, stacktraceOptions(), nil)
+	if err != nil {
+		p.done(err)
+		return
+	}
+
+	ancestorsPanel.ancestors, err = client.Ancestors(curGid, maxAncestorsChain, ancestorsPanel.depth)
+	if err != nil {
+		p.done(err)
+		return
+	}
+
+	if ancestorsPanel.mode == ancestorsModeChildren {
+		ancestorsPanel.children, err = loadGoroutineChildren(curGid, len(ancestorsPanel.ancestors) > 0)
+	}
+
+	p.done(err)
+}
+
+// loadGoroutineChildren returns the list of goroutines created by gid,
+// using their creation ancestors, which are only available when the target
+// is running with GODEBUG=tracebackancestors. If haveAncestors is set gid
+// has creation ancestors, otherwise the availability of ancestors is
+// checked on a single goroutine before asking for the ancestors of every
+// goroutine.
+func loadGoroutineChildren(gid int, haveAncestors bool) ([]*api.Goroutine, error) {
+	lim := goroutinesPanel.limit
+	if lim == 0 {
+		lim = 100
+	}
+	// one more goroutine than the limit is requested to know if the list
+	// is truncated.
+	gs, err := client.ListGoroutines(0, lim+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(gs) > lim {
+		gs = gs[:lim]
+		ancestorsPanel.childrenTruncated = lim
+	}
+
+	r := []*api.Goroutine{}
+	probe := !haveAncestors // availability of ancestors must be checked
+
+	for _, g := range gs {
+		if g.ID == gid {
+			continue
+		}
+		if probe {
+			// goroutines started by the runtime have no creation ancestors
+			if fn := g.GoStatementLoc.Function; fn == nil || strings.HasPrefix(fn.Name(), "runtime.") {
+				continue
+			}
+		}
+		ancestors, err := client.Ancestors(g.ID, 1, 1)
+		available := err == nil && len(ancestors) > 0 && ancestors[0].Unreadable == ""
+		if probe {
+			if !available {
+				ancestorsPanel.ancestorsUnavailable = true
+				return nil, nil
+			}
+			probe = false
+		}
+		if available && int(ancestors[0].ID) == gid {
+			r = append(r, g)
+		}
+	}
+
+	return r, nil
+}
+
+func updateAncestors(container *nucular.Window) {
+	w := ancestorsPanel.asyncLoad.showRequest(container)
+	if w == nil {
+		return
+	}
+
+	w.MenubarBegin()
+	w.Row(20).Static(120, 70, 200)
+	configChanged := false
+	if w.PropertyInt("depth:", 1, &ancestorsPanel.depth, 200, 1, 5) {
+		configChanged = true
+	}
+	w.Label("Show:", "LC")
+	newmode := w.ComboSimple([]string{"Ancestors", "Created by this goroutine"}, ancestorsPanel.mode, 30)
+	if newmode != ancestorsPanel.mode {
+		ancestorsPanel.mode = newmode
+		configChanged = true
+	}
+	if configChanged {
+		go func() {
+			ancestorsPanel.asyncLoad.clear()
+			wnd.Changed()
+		}()
+	}
+	w.MenubarEnd()
+
+	switch ancestorsPanel.mode {
+	case ancestorsModeAncestors:
+		updateAncestorsChain(w)
+	case ancestorsModeChildren:
+		updateGoroutineChildren(w)
+	}
+}
+
+func updateAncestorsChain(w *nucular.Window) {
+	if ancestorsPanel.gid < 0 {
+		w.Row(posRowHeight).Dynamic(1)
+		w.Label("No goroutine selected", "LC")
+		return
+	}
+
+	if len(ancestorsPanel.ancestors) == 0 {
+		w.Row(posRowHeight).Dynamic(1)
+		w.Label("No ancestors available, run the target with GODEBUG=tracebackancestors=N", "LC")
+	}
+
+	// Each ancestor is nested inside the goroutine it created, so that
+	// collapsing a goroutine hides the whole chain above it.
+	pushed := 0
+	defer func() {
+		for i := 0; i < pushed; i++ {
+			w.TreePop()
+		}
+	}()
+
+	w.Row(varRowHeight).Dynamic(1)
+	if !w.TreePushNamed(nucular.TreeTab, fmt.Sprintf("g%d", ancestorsPanel.gid), fmt.Sprintf("Goroutine %d", ancestorsPanel.gid), true) {
+		return
+	}
+	pushed++
+	showAncestorStack(w, ancestorsPanel.stack)
+
+	for i := range ancestorsPanel.ancestors {
+		a := &ancestorsPanel.ancestors[i]
+		w.Row(varRowHeight).Dynamic(1)
+		if a.Unreadable != "" {
+			w.Label(fmt.Sprintf("Unreadable ancestor: %s", a.Unreadable), "LC")
+			return
+		}
+		if !w.TreePushNamed(nucular.TreeNode, fmt.Sprintf("g%d", a.ID), fmt.Sprintf("Created by Goroutine %d", a.ID), false) {
+			return
+		}
+		pushed++
+		showAncestorStack(w, a.Stack)
+	}
+}
+
+func showAncestorStack(w *nucular.Window, stack []api.Stackframe) {
+	if !w.TreePush(nucular.TreeNode, "Stack", false) {
+		return
+	}
+	didx := digits(len(stack))
+	for i := range stack {
+		w.Row(posRowHeight).Static()
+		w.LayoutFitWidth(ancestorsPanel.id, 1)
+		w.Label(fmt.Sprintf("%*d", didx, i), "LT")
+		w.LayoutFitWidth(ancestorsPanel.id, 100)
+		w.Label(formatLocation2(stack[i].Location), "LT")
+	}
+	w.TreePop()
+}
+
+func updateGoroutineChildren(w *nucular.Window) {
+	if ancestorsPanel.gid < 0 {
+		w.Row(posRowHeight).Dynamic(1)
+		w.Label("No goroutine selected", "LC")
+		return
+	}
+
+	if ancestorsPanel.ancestorsUnavailable {
+		w.Row(posRowHeight).Dynamic(1)
+		w.Label("Creation ancestors not available, run the target with GODEBUG=tracebackancestors=N", "LC")
+		return
+	}
+
+	if ancestorsPanel.childrenTruncated > 0 {
+		w.Row(posRowHeight).Dynamic(1)
+		w.Label(fmt.Sprintf("Only the first %d goroutines were searched, increase the limit in the Goroutines panel to search more", ancestorsPanel.childrenTruncated), "LC")
+	}
+
+	if len(ancestorsPanel.children) == 0 {
+		w.Row(posRowHeight).Dynamic(1)
+		w.Label(fmt.Sprintf("No goroutines created by goroutine %d", ancestorsPanel.gid), "LC")
+		return
+	}
+
+	d := digits(ancestorsPanel.children[len(ancestorsPanel.children)-1].ID)
+
+	for _, g := range ancestorsPanel.children {
+		selected := false
+		w.Row(posRowHeight).Static()
+		w.LayoutFitWidth(ancestorsPanel.id, 1)
+		w.SelectableLabel(fmt.Sprintf("%*d", d, g.ID), "LT", &selected)
+		w.LayoutFitWidth(ancestorsPanel.id, 100)
+		w.SelectableLabel(formatLocation2(g.UserCurrentLoc), "LT", &selected)
+
+		if selected && !client.Running() {
+			go func(gid int) {
+				state, err := client.SwitchGoroutine(gid)
+				if err != nil {
+					out := editorWriter{true}
+					fmt.Fprintf(&out, "Could not switch goroutine: %v\n", err)
+				} else {
+					go refreshState(refreshToUserFrame, clearGoroutineSwitch, state)
+				}
+			}(g.ID)
+		}
+	}
+}
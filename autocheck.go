@@ -169,7 +169,8 @@ func addcheck(cnt int, gid int, bp *api.Breakpoint, bpi *api.BreakpointInfo) {
 	if bpi != nil {
 		for i := range bpi.Variables {
 			v := &bpi.Variables[i]
-			vars = append(vars, wrapApiVariable(v, v.Name, v.Name, true, 0))
+			wv := loadVariable(v, v.Name, v.Name)
+			vars = append(vars, wv)
 		}
 	}
 
@@ -358,7 +359,7 @@ func autoCheckpointsReloadVars() {
 			if err != nil {
 				panic(err)
 			}
-			check.Variables[i] = wrapApiVariable(v, v.Name, v.Name, true, 0)
+			check.Variables[i] = loadVariable(v, v.Name, v.Name)
 		}
 	}
 }
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/aarzilli/nucular"
+)
+
+// dataTreeNode is a node of a decoded JSON document or protobuf message.
+type dataTreeNode struct {
+	Key      string
+	Value    string
+	Children []*dataTreeNode
+}
+
+// decodeJSONTree decodes a JSON document, keeping the order of object
+// keys.
+func decodeJSONTree(buf []byte) (*dataTreeNode, error) {
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+	n, err := decodeJSONValue(dec, "")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("extra data after JSON value")
+	}
+	return n, nil
+}
+
+func decodeJSONValue(dec *json.Decoder, key string) (*dataTreeNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	n := &dataTreeNode{Key: key}
+	switch tok := tok.(type) {
+	case json.Delim:
+		switch tok {
+		case '{':
+			for dec.More() {
+				ktok, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				child, err := decodeJSONValue(dec, ktok.(string))
+				if err != nil {
+					return nil, err
+				}
+				n.Children = append(n.Children, child)
+			}
+			n.Value = fmt.Sprintf("{%d fields}", len(n.Children))
+		case '[':
+			for dec.More() {
+				child, err := decodeJSONValue(dec, fmt.Sprintf("[%d]", len(n.Children)))
+				if err != nil {
+					return nil, err
+				}
+				n.Children = append(n.Children, child)
+			}
+			n.Value = fmt.Sprintf("[%d elements]", len(n.Children))
+		}
+		// closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+	case string:
+		n.Value = strconv.Quote(tok)
+	case nil:
+		n.Value = "null"
+	default:
+		n.Value = fmt.Sprint(tok)
+	}
+	return n, nil
+}
+
+const maxProtobufDepth = 16
+
+// decodeProtobuf decodes buf as a protobuf message without a schema.
+// Length delimited fields are shown as strings if they are printable,
+// as nested messages if they can be decoded as such and as bytes
+// otherwise.
+func decodeProtobuf(buf []byte, depth int) ([]*dataTreeNode, error) {
+	var r []*dataTreeNode
+	for len(buf) > 0 {
+		key, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return nil, errors.New("malformed field key")
+		}
+		buf = buf[n:]
+		field, wiretype := key>>3, key&7
+		if field == 0 {
+			return nil, errors.New("invalid field number 0")
+		}
+		node := &dataTreeNode{Key: fmt.Sprintf("%d", field)}
+		switch wiretype {
+		case 0: // varint
+			v, n := binary.Uvarint(buf)
+			if n <= 0 {
+				return nil, errors.New("malformed varint")
+			}
+			buf = buf[n:]
+			node.Value = strconv.FormatUint(v, 10)
+			if int64(v) < 0 {
+				// negative int32 and int64 values are sign extended
+				node.Value += fmt.Sprintf(" (int64 %d)", int64(v))
+			}
+		case 1: // 64bit
+			if len(buf) < 8 {
+				return nil, errors.New("truncated fixed64")
+			}
+			v := binary.LittleEndian.Uint64(buf)
+			buf = buf[8:]
+			node.Value = fmt.Sprintf("%#016x (double %g)", v, math.Float64frombits(v))
+		case 5: // 32bit
+			if len(buf) < 4 {
+				return nil, errors.New("truncated fixed32")
+			}
+			v := binary.LittleEndian.Uint32(buf)
+			buf = buf[4:]
+			node.Value = fmt.Sprintf("%#08x (float %g)", v, math.Float32frombits(v))
+		case 2: // length delimited
+			l, n := binary.Uvarint(buf)
+			if n <= 0 || uint64(len(buf)-n) < l {
+				return nil, errors.New("truncated length delimited field")
+			}
+			data := buf[n : n+int(l)]
+			buf = buf[n+int(l):]
+			switch {
+			case isPrintable(data):
+				node.Value = strconv.Quote(string(data))
+			case depth < maxProtobufDepth:
+				if children, err := decodeProtobuf(data, depth+1); err == nil && len(children) > 0 {
+					node.Value = fmt.Sprintf("message (%d bytes)", len(data))
+					node.Children = children
+					break
+				}
+				fallthrough
+			default:
+				node.Value = fmt.Sprintf("bytes %x", data)
+			}
+		default:
+			return nil, fmt.Errorf("unsupported wire type %d", wiretype)
+		}
+		r = append(r, node)
+	}
+	return r, nil
+}
+
+func isPrintable(buf []byte) bool {
+	if len(buf) == 0 || !utf8.Valid(buf) {
+		return false
+	}
+	for _, ch := range string(buf) {
+		if !unicode.IsPrint(ch) && !unicode.IsSpace(ch) {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeHex decodes a hex string, whitespace and a 0x prefix are ignored.
+func decodeHex(buf []byte) ([]byte, error) {
+	s := strings.Map(func(ch rune) rune {
+		if unicode.IsSpace(ch) {
+			return -1
+		}
+		return ch
+	}, string(buf))
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	return hex.DecodeString(s)
+}
+
+// decodeBase64 decodes buf trying the standard and URL encodings, with and
+// without padding.
+func decodeBase64(buf []byte) ([]byte, error) {
+	s := strings.Map(func(ch rune) rune {
+		if unicode.IsSpace(ch) {
+			return -1
+		}
+		return ch
+	}, string(buf))
+	var err error
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		var out []byte
+		out, err = enc.DecodeString(s)
+		if err == nil {
+			return out, nil
+		}
+	}
+	return nil, err
+}
+
+// viewDecoded sets up the views that decode the contents of a string or
+// byte slice.
+func (dv *detailViewer) viewDecoded(buf []byte) {
+	dv.tree = nil
+	dv.decodeErr = nil
+	switch dv.stringMode {
+	case viewJSON:
+		var n *dataTreeNode
+		n, dv.decodeErr = decodeJSONTree(buf)
+		if n != nil {
+			dv.tree = []*dataTreeNode{n}
+		}
+	case viewProtobuf:
+		dv.tree, dv.decodeErr = decodeProtobuf(buf, 0)
+	case viewHex, viewBase64:
+		var out []byte
+		if dv.stringMode == viewHex {
+			out, dv.decodeErr = decodeHex(buf)
+		} else {
+			out, dv.decodeErr = decodeBase64(buf)
+		}
+		if dv.decodeErr == nil {
+			dv.viewStringAsByteArray(out)
+		}
+	}
+	dv.treeID++
+}
+
+func showDataTree(w *nucular.Window, nodes []*dataTreeNode, depth int) {
+	for i, n := range nodes {
+		title := n.Value
+		if n.Key != "" {
+			title = n.Key + ": " + n.Value
+		}
+		if len(n.Children) == 0 {
+			w.Row(posRowHeight).Dynamic(1)
+			w.Label(title, "LC")
+			continue
+		}
+		if w.TreePushNamed(nucular.TreeNode, strconv.Itoa(i), title, depth == 0) {
+			showDataTree(w, n.Children, depth+1)
+			w.TreePop()
+		}
+	}
+}
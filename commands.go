@@ -19,6 +19,7 @@ import (
 	"golang.org/x/mobile/event/key"
 
 	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+	"github.com/aarzilli/gdlv/internal/prettyprint"
 
 	"github.com/aarzilli/nucular"
 	"github.com/aarzilli/nucular/label"
@@ -178,12 +179,18 @@ Type 'help scope-expr' for a description of <scope-expr>.`},
 		{aliases: []string{"set"}, group: dataCmds, cmdFn: setVar, complete: completeVariable, helpMsg: `Changes the value of a variable.
 
 	set <variable> = <value>
+	set $<register> = <value>
 
-See $GOPATH/src/github.com/go-delve/delve/Documentation/cli/expr.md for a description of supported expressions. Only numerical variables and pointers can be changed.`},
+See $GOPATH/src/github.com/go-delve/delve/Documentation/cli/expr.md for a description of supported expressions. Only numerical variables and pointers can be changed.
+
+The second form changes the value of a register of the current thread, for example 'set $rax = 10'.`},
 		{aliases: []string{"display", "disp", "dp"}, group: dataCmds, complete: completeVariable, cmdFn: displayVar, helpMsg: `Adds one expression to the Variables panel.
 	
 	display [@<scope-expr>] <expression>
 	display [@<scope-expr>] $ <starlark-expression>
+	display -if <condition> <expression>
+
+With -if the expression is only evaluated when the condition, which is either an expression or a starlark expression starting with '$', is true, otherwise the expression is shown greyed out. The condition is evaluated in the current scope unless it has its own scope expression. A starlark condition can not contain spaces.
 
 See $GOPATH/src/github.com/go-delve/delve/Documentation/cli/expr.md for a description of supported expressions.
 Type 'help scope-expr' for a description of <scope-expr>.`},
@@ -191,6 +198,15 @@ Type 'help scope-expr' for a description of <scope-expr>.`},
 	
 	details <expr>
 `},
+		{aliases: []string{"diff"}, group: dataCmds, complete: completeVariable, cmdFn: diffCommand, helpMsg: `Compares two values and shows the paths where they differ.
+
+	diff <expr1> <expr2>
+	diff -snapshot <expr>
+	diff -list
+
+The first form opens a window listing the fields, elements and map keys that changed, were added or were removed between expr1 and expr2. Either expression can be a reference to a snapshot, #<n>.
+The second form saves a snapshot of the current value of expr, the third form lists saved snapshots.
+Type 'help scope-expr' for a description of <scope-expr>.`},
 		{aliases: []string{"layout"}, group: winCmds, cmdFn: layoutCommand, helpMsg: `Manages window layout.
 	
 	layout <name>
@@ -226,7 +242,7 @@ With the 'zoom' subcommand changes the display scaling factor (makes fonts large
 	
 	window <kind>
 	
-Kind is one of listing, diassembly, goroutines, stacktrace, variables, globals, breakpoints, threads, registers, sources, functions, types and checkpoints.
+Kind is one of listing, diassembly, goroutines, stacktrace, variables, globals, breakpoints, threads, registers, sources, functions, types, checkpoints and ancestors.
 
 Shortcuts:
 	Alt-1	Listing window
@@ -279,9 +295,21 @@ print @g2f8 a+1
 
 The text "@g2f8" is a scope expression describing that the expression "a+1" should be executed in the 8th frame (f8) of goroutine 2 (g2).
 
-A scope expression always starts with an '@' character and should contain either a goroutine specifier, a frame specifier or both.
+A scope expression always starts with an '@' character and should contain either a goroutine or thread specifier, a frame specifier or both.
+
+There are three kinds of goroutine specifiers:
+
+1. The character 'g' followed by a positive integer specifies the goroutine ID. The integer can be specified in decimal or in hexadecimal, following a '0x' prefix.
+
+2. The character 'g' followed by a list of comma separated key=value pairs delimited by '{' and '}', for example '@g{request_id=42}'. This specifies the goroutine whose pprof labels contain all the pairs, it is an error if more than one goroutine matches.
+
+3. The character 'g' followed by a regular expression delimited by the character '/'. This specifies the first goroutine whose user location matches the regular expression, either its function name or its file:line.
 
-A goroutine specifier is a positive integer following the character 'g'. The integer can be specified in decimal or in hexadecimal, following a '0x' prefix.
+The goroutine specifier 'g*' evaluates the expression in every goroutine that has the specified frame. The result is a list containing, for each goroutine, its ID, the frame and the value of the expression, the number of goroutines is limited by the maximum array length of the current load configuration. Use the 'details' command, or the Details menu item of the Variables panel, to display it as a table. For example 'print @g* @f/handler/ req.URL.Path' prints the URL of every request being handled.
+
+A thread specifier is the character 't' followed by a positive integer, the thread ID. A thread specifier can not be used together with a goroutine specifier. It is an error to specify a thread that is not running a goroutine, unless it is the current thread.
+
+A scope expression can be split into multiple parts, each one starting with '@', for example '@g* @f/handler/'.
 
 There are three kinds of frame specifiers:
 
@@ -661,6 +689,7 @@ func doRestart(out io.Writer, restartCheckpoint string, resetArgs bool, args []s
 		finishRestart(out, true)
 	}
 	refreshState(refreshToFrameZero, clearStop, nil)
+	runRestartHook()
 	return nil
 }
 
@@ -691,6 +720,7 @@ func doRebuild(out io.Writer, resetArgs bool, args []string) error {
 	finishRestart(out, true)
 
 	refreshState(refreshToFrameZero, clearStop, nil)
+	runRestartHook()
 	return nil
 }
 
@@ -1017,7 +1047,7 @@ func printVar(out io.Writer, args string) error {
 	args = replaceRegs(args)
 
 	val := evalScopedExpr(args, getVariableLoadConfig())
-	valstr := wrapApiVariableSimple(val).MultilineString("")
+	valstr := prettyprint.Multiline(loadVariable(val, val.Name, args).formattedApiVariable(), "")
 	nlcount := 0
 	for _, ch := range valstr {
 		if ch == '\n' {
@@ -1040,12 +1070,45 @@ func printVar(out io.Writer, args string) error {
 }
 
 func displayVar(out io.Writer, args string) error {
+	if strings.HasPrefix(args, "-if ") {
+		cond, expr, err := splitDisplayCond(args[len("-if "):])
+		if err != nil {
+			return err
+		}
+		addConditionalExpression(cond, expr)
+		return nil
+	}
 	addExpression(args)
 	return nil
 }
 
+// splitDisplayCond splits the arguments of 'display -if' in a condition and
+// an expression.
+func splitDisplayCond(args string) (string, string, error) {
+	cond, expr, ok := splitTwoExprs(args, validScopedExpr, func(s string) bool {
+		se := ParseScopedExpr(s)
+		if se.Kind == InvalidScopeExpr {
+			return false
+		}
+		if len(se.EvalExpr) > 0 && se.EvalExpr[0] == '$' {
+			// starlark expressions shown in the Variables panel can contain spaces
+			return true
+		}
+		_, err := parser.ParseExpr(se.EvalExpr)
+		return err == nil
+	})
+	if !ok {
+		return "", "", errors.New("could not split arguments in a condition and an expression")
+	}
+	return cond, expr, nil
+}
+
 func detailsVar(out io.Writer, args string) error {
-	newDetailViewer(wnd, args)
+	mw, ok := wnd.(nucular.MasterWindow)
+	if !ok {
+		return errors.New("details window not available without a window")
+	}
+	newDetailViewer(mw, args)
 	return nil
 }
 
@@ -1070,6 +1133,10 @@ func listCommand(out io.Writer, args string) error {
 }
 
 func setVar(out io.Writer, args string) error {
+	if lexpr, rexpr, ok := splitRegisterAssignment(args); ok {
+		return setRegister(lexpr, rexpr)
+	}
+
 	// HACK: in go '=' is not an operator, we detect the error and try to recover from it by splitting the input string
 	_, err := parser.ParseExpr(args)
 	if err == nil {
@@ -1086,6 +1153,42 @@ func setVar(out io.Writer, args string) error {
 	return client.SetVariable(currentEvalScope(), lexpr, rexpr)
 }
 
+// splitRegisterAssignment splits an assignment to a register, in the form
+// $reg = value.
+func splitRegisterAssignment(args string) (reg, value string, ok bool) {
+	args = strings.TrimSpace(args)
+	if !strings.HasPrefix(args, "$") {
+		return "", "", false
+	}
+	idx := strings.Index(args, "=")
+	if idx < 0 {
+		return "", "", false
+	}
+	reg, value = strings.TrimSpace(args[:idx]), strings.TrimSpace(args[idx+1:])
+	if len(reg) < 2 || value == "" || value[0] == '=' {
+		// $reg == value is a comparison
+		return "", "", false
+	}
+	for _, ch := range reg[1:] {
+		if !unicode.IsLetter(ch) && !unicode.IsDigit(ch) && ch != '_' {
+			return "", "", false
+		}
+	}
+	return reg, value, true
+}
+
+// setRegister changes the value of a register of the current thread,
+// since this can change the current location and the value of any
+// variable everything is reloaded.
+func setRegister(reg, value string) error {
+	err := client.SetVariable(currentEvalScope(), reg, value)
+	if err != nil {
+		return err
+	}
+	go refreshState(refreshToSameFrame, clearGoroutineSwitch, nil)
+	return nil
+}
+
 // ExitRequestError is returned when the user
 // exits Delve.
 type ExitRequestError struct{}
@@ -1146,6 +1249,9 @@ func layoutCommand(out io.Writer, args string) error {
 			description = argv[2]
 		}
 
+		if headless() {
+			return errors.New("can not save a layout without a window")
+		}
 		conf.Layouts[name] = LayoutDescr{Description: description, Layout: serializeLayout()}
 		saveConfiguration()
 	default:
@@ -1153,6 +1259,9 @@ func layoutCommand(out io.Writer, args string) error {
 		if !ok {
 			return fmt.Errorf("unknown layout %q", argv[0])
 		}
+		if headless() {
+			return errors.New("can not load a layout without a window")
+		}
 		loadPanelDescrToplevel(ld.Layout)
 		wnd.Changed()
 	}
@@ -1214,6 +1323,8 @@ type configWindow struct {
 	selectedSubstitutionRule int
 	from                     nucular.TextEditor
 	to                       nucular.TextEditor
+	selectedStarlarkDir      int
+	starlarkDir              nucular.TextEditor
 }
 
 func newConfigWindow() *configWindow {
@@ -1221,6 +1332,8 @@ func newConfigWindow() *configWindow {
 		selectedSubstitutionRule: -1,
 		from:                     nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditClipboard},
 		to:                       nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditClipboard},
+		selectedStarlarkDir:      -1,
+		starlarkDir:              nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditClipboard},
 	}
 }
 
@@ -1286,6 +1399,9 @@ func (cw *configWindow) Update(w *nucular.Window) {
 	if conf.MaxStringLen == 0 {
 		conf.MaxStringLen = LongLoadConfig.MaxStringLen
 	}
+	if conf.ExprHistorySize == 0 {
+		conf.ExprHistorySize = defaultExprHistorySize
+	}
 
 	w.Row(30).Static(0)
 
@@ -1296,6 +1412,10 @@ func (cw *configWindow) Update(w *nucular.Window) {
 	w.Spacing(1)
 	w.PropertyInt("Max string load:", 1, &conf.MaxStringLen, 4096, 1, 1)
 
+	w.Row(30).Static(200, 200)
+	w.Label("Expression history:", "LC")
+	w.PropertyInt("Max values:", 1, &conf.ExprHistorySize, 100000, 10, 10)
+
 	w.Row(30).Static(0)
 	if w.TreePush(nucular.TreeTab, "Path substitutions:", false) {
 		w.Row(240).Static(0, 100)
@@ -1338,6 +1458,43 @@ func (cw *configWindow) Update(w *nucular.Window) {
 		w.TreePop()
 	}
 
+	w.Row(30).Static(0)
+	if w.TreePush(nucular.TreeTab, "Starlark search path:", false) {
+		w.Row(240).Static(0, 100)
+		if w := w.GroupBegin("starlark-path-list", nucular.WindowNoHScrollbar); w != nil {
+			w.Row(30).Static(0)
+			if len(conf.StarlarkPath) == 0 {
+				w.Label("(no directories)", "LC")
+			}
+			for i, dir := range conf.StarlarkPath {
+				s := cw.selectedStarlarkDir == i
+				w.SelectableLabel(dir, "LC", &s)
+				if s {
+					cw.selectedStarlarkDir = i
+				}
+			}
+			w.GroupEnd()
+		}
+		if w := w.GroupBegin("starlark-path-controls", nucular.WindowNoScrollbar); w != nil {
+			w.Row(30).Static(0)
+			if w.ButtonText("Remove") && cw.selectedStarlarkDir >= 0 && cw.selectedStarlarkDir < len(conf.StarlarkPath) {
+				copy(conf.StarlarkPath[cw.selectedStarlarkDir:], conf.StarlarkPath[cw.selectedStarlarkDir+1:])
+				conf.StarlarkPath = conf.StarlarkPath[:len(conf.StarlarkPath)-1]
+				cw.selectedStarlarkDir = -1
+			}
+			w.GroupEnd()
+		}
+		w.Row(30).Static(80, 300, 80)
+		w.Label("Directory:", "LC")
+		cw.starlarkDir.Edit(w)
+		if w.ButtonText("Add") && len(cw.starlarkDir.Buffer) > 0 {
+			conf.StarlarkPath = append(conf.StarlarkPath, string(cw.starlarkDir.Buffer))
+			cw.starlarkDir.Buffer = cw.starlarkDir.Buffer[:0]
+		}
+
+		w.TreePop()
+	}
+
 	w.Row(20).Static(0, 100)
 	w.Spacing(1)
 	if w.ButtonText("OK") {
@@ -1393,7 +1550,11 @@ func scrollCommand(out io.Writer, args string) error {
 func windowCommand(out io.Writer, args string) error {
 	args = strings.ToLower(strings.TrimSpace(args))
 	if args == "styled" {
-		styled.EditStyle(wnd, nucular.WindowNonmodal|nucular.WindowClosable, func(out string) {
+		mw, ok := wnd.(nucular.MasterWindow)
+		if !ok {
+			return errors.New("style editor not available without a window")
+		}
+		styled.EditStyle(mw, nucular.WindowNonmodal|nucular.WindowClosable, func(out string) {
 			fh, err := os.Create("boring-style.go")
 			if err != nil {
 				fmt.Fprintf(os.Stderr, "error creating boring-style.go: %v", err)
@@ -1456,7 +1617,12 @@ func sourceCommand(out io.Writer, args string) error {
 	}
 
 	if args == "-" {
+		wnd.Lock()
+		savedHistory := cmdhistory
+		cmdhistory = loadStarlarkHistory()
+		historyShown = len(cmdhistory)
 		starlarkMode = make(chan string)
+		wnd.Unlock()
 		promptChan := make(chan string)
 		go func() {
 			for pmpt := range promptChan {
@@ -1467,6 +1633,8 @@ func sourceCommand(out io.Writer, args string) error {
 			}
 			wnd.Lock()
 			starlarkMode = nil
+			cmdhistory = savedHistory
+			historyShown = len(cmdhistory)
 			wnd.Unlock()
 			wnd.Changed()
 		}()
@@ -1790,6 +1958,7 @@ func executeCommand(cmdstr string) {
 // server is multiclient), what to do about the target process (if we
 // attached to it) and then exits.
 func handleExitRequest() {
+	runExitHook(nil)
 	if client != nil && curThread >= 0 && client.IsMulticlient() {
 		wnd.PopupOpen("Quit Action", dynamicPopupFlags, rect.Rect{100, 100, 500, 700}, true, func(w *nucular.Window) {
 			w.Row(20).Dynamic(1)
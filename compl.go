@@ -8,6 +8,7 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+	"unicode"
 )
 
 var fullpathCompl []string
@@ -37,6 +38,10 @@ func completeLocationSetup() {
 }
 
 func completeAny() {
+	if starlarkMode != nil {
+		completeStarlark()
+		return
+	}
 	buf := commandLineEditor.Buffer
 	if len(buf) == commandLineEditor.Cursor {
 		completeCommand()
@@ -129,6 +134,21 @@ func completeVariable() {
 	cm.finish()
 }
 
+// completeStarlark completes the identifier, or attribute path, before the
+// cursor in the starlark REPL.
+func completeStarlark() {
+	buf := commandLineEditor.Buffer[:commandLineEditor.Cursor]
+	start := len(buf)
+	for start > 0 && (buf[start-1] == '_' || buf[start-1] == '.' || unicode.IsLetter(buf[start-1]) || unicode.IsDigit(buf[start-1])) {
+		start--
+	}
+	word := string(buf[start:])
+	if word == "" {
+		return
+	}
+	completeWord(word, StarlarkEnv.Complete(word))
+}
+
 func completeCommand() {
 	if cmds == nil || len(commandLineEditor.Buffer) == 0 {
 		return
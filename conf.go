@@ -32,7 +32,9 @@ type Configuration struct {
 	SavedBounds          map[string]rect.Rect
 	MaxArrayValues       int
 	MaxStringLen         int
+	ExprHistorySize      int
 	SubstitutePath       []SubstitutePathRule
+	StarlarkPath         []string // directories searched by the load statement of starlark scripts
 	FrozenBreakpoints    map[string][]frozenBreakpoint
 	DisabledBreakpoints  map[string][]frozenBreakpoint
 }
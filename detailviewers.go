@@ -2,10 +2,13 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math"
 	"reflect"
 	"strconv"
+	"strings"
 	"sync"
 
 	"go.starlark.net/starlark"
@@ -36,6 +39,16 @@ type detailViewer struct {
 	numberMode numberMode
 	ed         nucular.TextEditor
 
+	table           *tableViewer
+	fanOutTruncated bool // the table does not contain the results for all goroutines
+
+	plotMode bool
+	plot     plotView
+
+	tree      []*dataTreeNode
+	treeID    int
+	decodeErr error
+
 	mu sync.Mutex
 }
 
@@ -45,8 +58,14 @@ const (
 	viewString stringViewerMode = iota
 	viewByteArray
 	viewRuneArray
+	viewJSON
+	viewHex
+	viewBase64
+	viewProtobuf
 )
 
+var stringViewerModeNames = []string{"string", "[]byte", "[]rune", "JSON", "hex", "base64", "protobuf"}
+
 func newDetailViewer(mw nucular.MasterWindow, expr string) {
 	r := &detailViewer{}
 
@@ -64,7 +83,21 @@ func (dv *detailViewer) load(p *asyncLoad) {
 	expr := string(dv.exprEd.Buffer)
 	dv.v = nil
 	dv.loadErr = nil
-	v, err := client.EvalVariable(currentEvalScope(), expr, api.LoadConfig{false, 0, dv.len, dv.len, -1})
+	se := ParseScopedExpr(expr)
+	fanout := se.Gall && se.Kind != InvalidScopeExpr
+	dv.fanOutTruncated = false
+	var v *api.Variable
+	var err error
+	if fanout {
+		// results of expressions evaluated in all goroutines are always
+		// displayed as a table
+		v, dv.fanOutTruncated = evalFanOutExpr(expr, &se, tableLoadConfig(dv.len))
+		if v.Unreadable != "" {
+			err = errors.New(v.Unreadable)
+		}
+	} else {
+		v, err = client.EvalVariable(currentEvalScope(), expr, api.LoadConfig{false, 0, dv.len, dv.len, -1})
+	}
 	if err != nil {
 		dv.loadErr = err
 		if p != nil {
@@ -73,18 +106,49 @@ func (dv *detailViewer) load(p *asyncLoad) {
 		return
 	}
 
-	dv.v = wrapApiVariable(v, v.Name, v.Name, true, 0)
+	if fanout {
+		if dv.table == nil {
+			dv.table = newTableViewer()
+		}
+		dv.table.setRows(v.Children)
+	} else if isStructSlice(v) {
+		v, err = client.EvalVariable(currentEvalScope(), expr, tableLoadConfig(dv.len))
+		if err != nil {
+			dv.loadErr = err
+			if p != nil {
+				p.done(nil)
+			}
+			return
+		}
+		if dv.table == nil {
+			dv.table = newTableViewer()
+		}
+		dv.table.setRows(v.Children)
+	} else {
+		dv.table = nil
+	}
+
+	dv.v = loadVariable(v, v.Name, v.Name)
 
 	switch dv.v.Type {
 	case "string":
 		dv.stringMode = viewString
+		if json.Valid([]byte(dv.v.Value)) && strings.ContainsAny(dv.v.Value, "{[") {
+			dv.stringMode = viewJSON
+		}
 	case "[]uint8":
 		dv.stringMode = viewByteArray
+		if buf := byteSliceValue(dv.v); json.Valid(buf) && bytes.ContainsAny(buf, "{[") {
+			dv.stringMode = viewJSON
+		}
 	case "[]int32":
 		dv.stringMode = viewRuneArray
 	}
 
 	dv.loaded = fmt.Sprintf("%s (loaded: %d/%d)", expr, dv.length(), dv.v.Len)
+	if dv.fanOutTruncated {
+		dv.loaded = fmt.Sprintf("%s (loaded: %d goroutines, more not examined)", expr, dv.length())
+	}
 	dv.setupView()
 
 	if p != nil {
@@ -105,15 +169,13 @@ func (dv *detailViewer) setupView() {
 			dv.viewStringAsByteArray([]byte(dv.v.Value))
 		case viewRuneArray:
 			dv.viewStringAsRuneArray([]rune(dv.v.Value))
+		default:
+			dv.viewDecoded([]byte(dv.v.Value))
 		}
 		return
 
 	case "[]uint8":
-		bytes := make([]byte, len(dv.v.Children))
-		for i := range dv.v.Children {
-			n, _ := strconv.Atoi(dv.v.Children[i].Variable.Value)
-			bytes[i] = byte(n)
-		}
+		bytes := byteSliceValue(dv.v)
 		switch dv.stringMode {
 		case viewString:
 			dv.ed.Buffer = []rune(string(bytes))
@@ -121,6 +183,8 @@ func (dv *detailViewer) setupView() {
 			dv.viewStringAsByteArray(bytes)
 		case viewRuneArray:
 			dv.viewStringAsRuneArray([]rune(string(bytes)))
+		default:
+			dv.viewDecoded(bytes)
 		}
 		return
 
@@ -137,10 +201,15 @@ func (dv *detailViewer) setupView() {
 			dv.viewStringAsByteArray([]byte(string(runes)))
 		case viewRuneArray:
 			dv.viewStringAsRuneArray(runes)
+		default:
+			dv.viewDecoded([]byte(string(runes)))
 		}
 		return
 
-	case "[]int", "[]int8", "[]int16", "[]int64", "[]uint", "[]uint16", "[]uint32", "[]uint64":
+	}
+
+	switch numericArrayKind(dv.v) {
+	case reflect.Int:
 		array := make([]int64, len(dv.v.Children))
 		max := int64(0)
 		for i := range dv.v.Children {
@@ -160,12 +229,31 @@ func (dv *detailViewer) setupView() {
 
 		size := int(math.Ceil((math.Log(float64(max)) / math.Log(2)) / 8))
 		dv.ed.Buffer = []rune(formatArray(array, dv.numberMode != decMode, dv.numberMode, false, size, 10))
+		dv.plot.setValues(dv.v.Children)
+
+	case reflect.Float64:
+		var buf bytes.Buffer
+		d := digits(len(dv.v.Children))
+		for i := range dv.v.Children {
+			fmt.Fprintf(&buf, "[%*d]  %s\n", d, i, dv.v.Children[i].Variable.Value)
+		}
+		dv.ed.Buffer = []rune(buf.String())
+		dv.plot.setValues(dv.v.Children)
 
 	default:
 		dv.ed.Buffer = []rune(fmt.Sprintf("unsupported type %s", dv.v.Type))
 	}
 }
 
+func byteSliceValue(v *Variable) []byte {
+	bytes := make([]byte, len(v.Children))
+	for i := range v.Children {
+		n, _ := strconv.Atoi(v.Children[i].Variable.Value)
+		bytes[i] = byte(n)
+	}
+	return bytes
+}
+
 func (dv *detailViewer) viewStringAsByteArray(bytes []byte) {
 	array := make([]int64, len(bytes))
 	for i := range bytes {
@@ -245,6 +333,13 @@ func formatArray(array []int64, hexaddr bool, mode numberMode, canonical bool, s
 	return buf.String()
 }
 
+// reload loads the expression again, the load happens on a separate
+// goroutine started by the next call to Update.
+func (dv *detailViewer) reload(w *nucular.Window) {
+	dv.asyncLoad.clear()
+	w.Master().Changed()
+}
+
 func (dv *detailViewer) Update(container *nucular.Window) {
 	w := dv.asyncLoad.showRequest(container)
 	if w == nil {
@@ -255,14 +350,18 @@ func (dv *detailViewer) Update(container *nucular.Window) {
 	w.Label("Expression: ", "LC")
 	active := dv.exprEd.Edit(w)
 	if active&nucular.EditCommitted != 0 {
-		dv.load(nil)
+		dv.reload(w)
 	}
 	if w.ButtonText("Set") {
-		dv.load(nil)
+		dv.reload(w)
 	}
 	if dv.v != nil {
-		if w.PropertyInt("Length:", 1, &dv.len, int(dv.v.Len), 16, 16) {
-			dv.load(nil)
+		max := int(dv.v.Len)
+		if dv.fanOutTruncated {
+			max = dv.len + LongArrayLoadConfig.MaxArrayValues
+		}
+		if w.PropertyInt("Length:", 1, &dv.len, max, 16, 16) {
+			dv.reload(w)
 		}
 	} else {
 		w.Spacing(1)
@@ -283,12 +382,19 @@ func (dv *detailViewer) Update(container *nucular.Window) {
 	w.Label("Showing: ", "LC")
 	w.Label(dv.loaded, "LC")
 
+	if dv.table != nil {
+		dv.tableUpdate(w)
+		return
+	}
+
 	switch dv.v.Type {
 	case "string", "[]uint8", "[]int32":
 		dv.stringUpdate(w)
-	case "[]int", "[]int8", "[]int16", "[]int64", "[]uint", "[]uint16", "[]uint32", "[]uint64":
-		dv.intArrayUpdate(w)
 	default:
+		if numericArrayKind(dv.v) != reflect.Invalid {
+			dv.numericArrayUpdate(w)
+			return
+		}
 		w.Row(30).Dynamic(1)
 		w.Label(fmt.Sprintf("Unsupported type %s", dv.v.Type), "LC")
 	}
@@ -300,7 +406,7 @@ func (dv *detailViewer) stringUpdate(w *nucular.Window) {
 
 	w.Row(20).Static(100, 100, 20, 100)
 	w.Label("View as:", "LC")
-	newmode := stringViewerMode(w.ComboSimple([]string{"string", "[]byte", "[]rune"}, int(dv.stringMode), 20))
+	newmode := stringViewerMode(w.ComboSimple(stringViewerModeNames, int(dv.stringMode), 20))
 	if newmode != dv.stringMode {
 		dv.stringMode = newmode
 		dv.setupView()
@@ -312,16 +418,33 @@ func (dv *detailViewer) stringUpdate(w *nucular.Window) {
 	case viewString:
 		// nothing to choose
 		w.Spacing(1)
-	case viewByteArray, viewRuneArray:
+	case viewByteArray, viewRuneArray, viewHex, viewBase64:
 		numberMode := numberMode(w.ComboSimple([]string{"Decimal", "Hexadecimal", "Octal"}, int(dv.numberMode), 20))
 		if numberMode != dv.numberMode {
 			dv.numberMode = numberMode
 			dv.setupView()
 		}
+	default:
+		w.Spacing(1)
 	}
 
-	w.Row(0).Dynamic(1)
-	dv.ed.Edit(w)
+	if dv.decodeErr != nil && dv.stringMode >= viewJSON {
+		w.Row(20).Dynamic(1)
+		w.LabelColored(fmt.Sprintf("Could not decode: %v", dv.decodeErr), "LC", errorColor)
+		return
+	}
+
+	switch dv.stringMode {
+	case viewJSON, viewProtobuf:
+		w.Row(0).Dynamic(1)
+		if w := w.GroupBegin(fmt.Sprintf("tree%d", dv.treeID), 0); w != nil {
+			showDataTree(w, dv.tree, 0)
+			w.GroupEnd()
+		}
+	default:
+		w.Row(0).Dynamic(1)
+		dv.ed.Edit(w)
+	}
 }
 
 func (dv *detailViewer) length() int {
@@ -336,13 +459,31 @@ func (dv *detailViewer) length() int {
 }
 
 func (dv *detailViewer) loadMore() {
+	if ParseScopedExpr(string(dv.exprEd.Buffer)).Gall {
+		if !dv.fanOutTruncated {
+			return
+		}
+		// results of expressions evaluated in all goroutines can not be
+		// sliced, evaluate the expression again in more goroutines
+		dv.len += LongArrayLoadConfig.MaxArrayValues
+		dv.asyncLoad.clear()
+		wnd.Changed()
+		return
+	}
+	if int64(dv.length()) >= dv.v.Len {
+		return
+	}
 	additionalLoadMu.Lock()
 	defer additionalLoadMu.Unlock()
 	if !additionalLoadRunning {
 		additionalLoadRunning = true
 		go func() {
 			expr := fmt.Sprintf("(*(*%q)(%#x))[%d:]", dv.v.RealType, dv.v.Addr, dv.length())
-			lv, err := client.EvalVariable(currentEvalScope(), expr, LongArrayLoadConfig)
+			cfg := LongArrayLoadConfig
+			if dv.table != nil {
+				cfg = tableLoadConfig(LongArrayLoadConfig.MaxArrayValues)
+			}
+			lv, err := client.EvalVariable(currentEvalScope(), expr, cfg)
 			if err != nil {
 				out := editorWriter{true}
 				fmt.Fprintf(&out, "Error loading string contents %s: %v\n", expr, err)
@@ -352,13 +493,18 @@ func (dv *detailViewer) loadMore() {
 					dv.v.Width = 0
 					dv.v.Value += lv.Value
 				case reflect.Array, reflect.Slice:
-					dv.v.Children = append(dv.v.Children, wrapApiVariables(lv.Children, dv.v.Kind, len(dv.v.Children), dv.v.Expression, true, 0)...)
+					children := loadVariables(lv.Children, dv.v.Kind, len(dv.v.Children), dv.v.Expression)
+					dv.v.Children = append(dv.v.Children, children...)
 				}
 			}
 			additionalLoadMu.Lock()
 			additionalLoadRunning = false
 			additionalLoadMu.Unlock()
 			dv.mu.Lock()
+			if dv.table != nil && err == nil {
+				dv.table.appendRows(lv.Children)
+			}
+			dv.loaded = fmt.Sprintf("%s (loaded: %d/%d)", dv.v.Expression, dv.length(), dv.v.Len)
 			dv.setupView()
 			dv.mu.Unlock()
 			wnd.Changed()
@@ -366,28 +512,44 @@ func (dv *detailViewer) loadMore() {
 	}
 }
 
-func (dv *detailViewer) intArrayUpdate(w *nucular.Window) {
+func (dv *detailViewer) numericArrayUpdate(w *nucular.Window) {
 	if dv.len != len(dv.v.Children) {
 		dv.setupView()
 	}
 
-	w.Row(20).Static(100, 120, 120, 120)
+	isint := numericArrayKind(dv.v) == reflect.Int
+
+	w.Row(20).Static(100, 120, 120, 120, 120)
 	w.Label("View as:", "LC")
 	mode := dv.numberMode
-	if w.OptionText("Decimal", mode == decMode) {
-		mode = decMode
-	}
-	if w.OptionText("Hexadecimal", mode == hexMode) {
-		mode = hexMode
+	plotMode := dv.plotMode
+	if isint {
+		if w.OptionText("Decimal", !plotMode && mode == decMode) {
+			mode, plotMode = decMode, false
+		}
+		if w.OptionText("Hexadecimal", !plotMode && mode == hexMode) {
+			mode, plotMode = hexMode, false
+		}
+		if w.OptionText("Octal", !plotMode && mode == octMode) {
+			mode, plotMode = octMode, false
+		}
+	} else if w.OptionText("Values", !plotMode) {
+		plotMode = false
 	}
-	if w.OptionText("Octal", mode == octMode) {
-		mode = octMode
+	if w.OptionText("Plot", plotMode) {
+		plotMode = true
 	}
+	dv.plotMode = plotMode
 	if mode != dv.numberMode {
 		dv.numberMode = mode
 		dv.setupView()
 	}
 
+	if dv.plotMode {
+		dv.plot.update(w)
+		return
+	}
+
 	w.Row(0).Dynamic(1)
 	dv.ed.Edit(w)
 }
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/rect"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+)
+
+const defaultExprHistorySize = 100
+
+// exprHistory is the list of values taken by a pinned expression each
+// time the target stopped.
+type exprHistory struct {
+	entries []exprHistoryEntry
+	pathEd  nucular.TextEditor
+	status  string
+}
+
+type exprHistoryEntry struct {
+	When  time.Time
+	Loc   api.Location
+	Value string
+}
+
+func (h *exprHistory) add(loc api.Location, value string) {
+	size := conf.ExprHistorySize
+	if size <= 0 {
+		size = defaultExprHistorySize
+	}
+	h.entries = append(h.entries, exprHistoryEntry{time.Now(), loc, value})
+	if len(h.entries) > size {
+		h.entries = append(h.entries[:0], h.entries[len(h.entries)-size:]...)
+	}
+}
+
+// recordExprHistory appends the values just loaded for the pinned
+// expressions to their history, if the target stopped since the last time
+// they were recorded. Expressions whose condition is false are skipped.
+func recordExprHistory() {
+	wnd.Lock()
+	defer wnd.Unlock()
+	loc := localsPanel.historyLoc
+	if loc == nil {
+		return
+	}
+	localsPanel.historyLoc = nil
+	for i := range localsPanel.expressions {
+		expr := &localsPanel.expressions[i]
+		if expr.condFalse || i >= len(localsPanel.v) {
+			continue
+		}
+		if expr.history == nil {
+			expr.history = &exprHistory{}
+		}
+		expr.history.add(*loc, localsPanel.v[i].SinglelineString(true, false))
+	}
+}
+
+func (h *exprHistory) writeCSV(out io.Writer) error {
+	w := csv.NewWriter(out)
+	w.Write([]string{"time", "function", "file", "line", "pc", "value"})
+	for _, e := range h.entries {
+		w.Write([]string{e.When.Format(time.RFC3339Nano), e.Loc.Function.Name(), e.Loc.File, fmt.Sprintf("%d", e.Loc.Line), fmt.Sprintf("%#x", e.Loc.PC), e.Value})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (h *exprHistory) exportCSV(path string) error {
+	fh, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer fh.Close()
+	return h.writeCSV(fh)
+}
+
+func openExprHistory(mw nucular.MasterWindow, expr string, h *exprHistory) {
+	h.pathEd.Flags = nucular.EditSelectable | nucular.EditClipboard
+	if len(h.pathEd.Buffer) == 0 {
+		h.pathEd.Buffer = []rune("history.csv")
+	}
+	mw.PopupOpen(fmt.Sprintf("History of %s", expr), dynamicPopupFlags|nucular.WindowScalable, rect.Rect{100, 100, 600, 400}, true, h.update)
+}
+
+func (h *exprHistory) update(w *nucular.Window) {
+	w.Row(20).Static(0, 100, 100)
+	h.pathEd.Edit(w)
+	if w.ButtonText("Export CSV") {
+		if err := h.exportCSV(string(h.pathEd.Buffer)); err != nil {
+			h.status = fmt.Sprintf("Error: %v", err)
+		} else {
+			h.status = fmt.Sprintf("Saved %d entries", len(h.entries))
+		}
+	}
+	if w.ButtonText("Clear") {
+		h.entries = h.entries[:0]
+	}
+	if h.status != "" {
+		w.Row(20).Dynamic(1)
+		w.Label(h.status, "LC")
+	}
+
+	w.Row(0).Dynamic(1)
+	if w := w.GroupBegin("expr-history", 0); w != nil {
+		if len(h.entries) == 0 {
+			w.Row(posRowHeight).Dynamic(1)
+			w.Label("(no values recorded)", "LC")
+		}
+		d := digits(len(h.entries))
+		for i := len(h.entries) - 1; i >= 0; i-- {
+			e := &h.entries[i]
+			w.Row(posRowHeight).Static()
+			w.LayoutFitWidth(0, 1)
+			w.Label(fmt.Sprintf("%*d", d, i+1), "LT")
+			w.LayoutFitWidth(0, 1)
+			w.Label(e.When.Format("15:04:05.000"), "LT")
+			w.LayoutFitWidth(0, 100)
+			w.Label(formatLocation2(e.Loc), "LT")
+			w.LayoutFitWidth(0, 100)
+			w.Label(e.Value, "LT")
+		}
+		w.GroupEnd()
+	}
+}
@@ -0,0 +1,402 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"reflect"
+	"strconv"
+	"sync"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/clipboard"
+	"github.com/aarzilli/nucular/rect"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+)
+
+// imageTypes are the image types supported by the image viewer.
+var imageTypes = map[string]bool{
+	"*image.RGBA":     true,
+	"*image.NRGBA":    true,
+	"*image.Gray":     true,
+	"*image.YCbCr":    true,
+	"*image.Paletted": true,
+}
+
+const (
+	imageChunkSize   = 16 * 1024
+	maxImageBytes    = 64 * 1024 * 1024
+	maxScaledImageSz = 4096 * 4096
+)
+
+// imageZoomLevels are the zoom levels of the image viewer, negative
+// numbers are fractions (-2 is 1/2).
+var imageZoomLevels = []int{-8, -4, -2, 1, 2, 4, 8, 16}
+var imageZoomNames = []string{"12.5%", "25%", "50%", "100%", "200%", "400%", "800%", "1600%"}
+
+const defaultImageZoom = 3
+
+// imageType returns the concrete image type of v, looking inside
+// image.Image interfaces.
+func imageType(v *api.Variable) string {
+	if imageTypes[v.Type] {
+		return v.Type
+	}
+	if v.Type == "image.Image" && len(v.Children) == 1 && imageTypes[v.Children[0].Type] {
+		return v.Children[0].Type
+	}
+	return ""
+}
+
+type imageViewer struct {
+	expr string
+	typ  string
+
+	mu sync.Mutex
+
+	rect      image.Rectangle
+	stride    int
+	cstride   int
+	subsample image.YCbCrSubsampleRatio
+	palette   color.Palette
+
+	bufs   [3][]byte // Pix, or Y, Cb and Cr for YCbCr images
+	loaded int
+	total  int
+	err    error
+
+	img    image.Image
+	zoom   int
+	scaled *image.RGBA
+	hover  string
+}
+
+func newImageViewer(mw nucular.MasterWindow, expr string) {
+	iv := &imageViewer{expr: expr, zoom: defaultImageZoom}
+	go iv.load()
+	mw.PopupOpen(fmt.Sprintf("Image %s", expr), popupFlags|nucular.WindowNonmodal|nucular.WindowScalable|nucular.WindowClosable, rect.Rect{100, 100, 640, 480}, true, iv.Update)
+}
+
+func imageField(v *api.Variable, path ...string) *api.Variable {
+	for _, name := range path {
+		found := false
+		for i := range v.Children {
+			if v.Children[i].Name == name {
+				v = &v.Children[i]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil
+		}
+	}
+	return v
+}
+
+func imageIntField(v *api.Variable, path ...string) int {
+	f := imageField(v, path...)
+	if f == nil {
+		return 0
+	}
+	n, _ := strconv.Atoi(f.Value)
+	return n
+}
+
+func (iv *imageViewer) fail(err error) {
+	iv.mu.Lock()
+	iv.err = err
+	iv.mu.Unlock()
+	wnd.Changed()
+}
+
+// load reads the header of the image and then its pixel buffers, in
+// chunks, redrawing the image after each chunk.
+func (iv *imageViewer) load() {
+	v, err := client.EvalVariable(currentEvalScope(), iv.expr, api.LoadConfig{true, 4, 0, 0, -1})
+	if err != nil {
+		iv.fail(err)
+		return
+	}
+	if v.Kind == reflect.Interface && len(v.Children) == 1 {
+		v = &v.Children[0]
+	}
+	iv.typ = imageType(v)
+	if iv.typ == "" || len(v.Children) != 1 {
+		iv.fail(fmt.Errorf("unsupported type %s", v.Type))
+		return
+	}
+	if v.Children[0].Addr == 0 {
+		iv.fail(errors.New("nil image"))
+		return
+	}
+	s := &v.Children[0]
+
+	iv.rect = image.Rect(imageIntField(s, "Rect", "Min", "X"), imageIntField(s, "Rect", "Min", "Y"), imageIntField(s, "Rect", "Max", "X"), imageIntField(s, "Rect", "Max", "Y"))
+
+	var pix []*api.Variable
+	if iv.typ == "*image.YCbCr" {
+		iv.stride = imageIntField(s, "YStride")
+		iv.cstride = imageIntField(s, "CStride")
+		iv.subsample = image.YCbCrSubsampleRatio(imageIntField(s, "SubsampleRatio"))
+		pix = []*api.Variable{imageField(s, "Y"), imageField(s, "Cb"), imageField(s, "Cr")}
+	} else {
+		iv.stride = imageIntField(s, "Stride")
+		pix = []*api.Variable{imageField(s, "Pix")}
+	}
+
+	if iv.typ == "*image.Paletted" {
+		pv := imageField(s, "Palette")
+		if pv != nil && pv.Len > 0 {
+			lv, err := client.EvalVariable(currentEvalScope(), fmt.Sprintf("*(*%q)(%#x)", pv.Type, pv.Addr), api.LoadConfig{true, 2, 0, 256, -1})
+			if err != nil {
+				iv.fail(err)
+				return
+			}
+			iv.palette = imagePalette(lv)
+		}
+	}
+
+	var total int64
+	for _, p := range pix {
+		if p == nil || p.Len < 0 {
+			iv.fail(fmt.Errorf("could not read pixels of %s", iv.typ))
+			return
+		}
+		total += p.Len
+		if total > maxImageBytes {
+			iv.fail(fmt.Errorf("image too large (more than %d bytes)", maxImageBytes))
+			return
+		}
+	}
+	lens := make([]int, len(pix))
+	for i, p := range pix {
+		lens[i] = int(p.Len)
+	}
+	if err := checkImageLayout(iv.typ, iv.rect, iv.stride, iv.cstride, iv.subsample, lens); err != nil {
+		iv.fail(err)
+		return
+	}
+	for i, p := range pix {
+		iv.bufs[i] = make([]byte, p.Len)
+	}
+
+	iv.mu.Lock()
+	iv.total = int(total)
+	iv.decode()
+	iv.mu.Unlock()
+	wnd.Changed()
+
+	for i, p := range pix {
+		chunk := 0
+		err := client.ReadMemory(currentEvalScope(), p.Base, int(p.Len), imageChunkSize, func(off int, buf []byte) error {
+			iv.mu.Lock()
+			copy(iv.bufs[i][off:], buf)
+			iv.loaded += len(buf)
+			if iv.loaded == iv.total || chunk%16 == 0 {
+				// redrawing after every chunk is too slow for large images
+				iv.decode()
+			}
+			chunk++
+			iv.mu.Unlock()
+			wnd.Changed()
+			return nil
+		})
+		if err != nil {
+			iv.fail(err)
+			return
+		}
+	}
+}
+
+// checkImageLayout checks that the header of an image read from the target
+// describes pixels that are all inside its buffers, lens are the lengths of
+// the buffers (Pix, or Y, Cb and Cr for YCbCr images).
+func checkImageLayout(typ string, r image.Rectangle, stride, cstride int, subsample image.YCbCrSubsampleRatio, lens []int) error {
+	if r.Dx() > maxImageBytes || r.Dy() > maxImageBytes || r.Dx()*r.Dy() > maxImageBytes {
+		return fmt.Errorf("image too large (%dx%d)", r.Dx(), r.Dy())
+	}
+	if stride <= 0 {
+		return fmt.Errorf("invalid stride %d", stride)
+	}
+	if r.Empty() {
+		return nil
+	}
+	last := image.Pt(r.Max.X-1, r.Max.Y-1)
+	inside := func(off, n, buflen int) bool {
+		return off >= 0 && off+n <= buflen
+	}
+	switch typ {
+	case "*image.YCbCr":
+		if cstride <= 0 {
+			return fmt.Errorf("invalid chroma stride %d", cstride)
+		}
+		switch subsample {
+		case image.YCbCrSubsampleRatio444, image.YCbCrSubsampleRatio422, image.YCbCrSubsampleRatio420, image.YCbCrSubsampleRatio440, image.YCbCrSubsampleRatio411, image.YCbCrSubsampleRatio410:
+		default:
+			return fmt.Errorf("invalid subsample ratio %d", subsample)
+		}
+		img := &image.YCbCr{YStride: stride, CStride: cstride, SubsampleRatio: subsample, Rect: r}
+		if len(lens) != 3 || !inside(img.YOffset(last.X, last.Y), 1, lens[0]) || !inside(img.COffset(last.X, last.Y), 1, lens[1]) || !inside(img.COffset(last.X, last.Y), 1, lens[2]) {
+			return errors.New("image rectangle outside of pixel buffers")
+		}
+	default:
+		bpp := 1
+		if typ == "*image.RGBA" || typ == "*image.NRGBA" {
+			bpp = 4
+		}
+		off := (last.Y-r.Min.Y)*stride + (last.X-r.Min.X)*bpp
+		if len(lens) != 1 || !inside(off, bpp, lens[0]) {
+			return errors.New("image rectangle outside of pixel buffer")
+		}
+	}
+	return nil
+}
+
+// imagePalette converts a color.Palette loaded from the target, entries
+// of unknown types are black.
+func imagePalette(v *api.Variable) color.Palette {
+	r := make(color.Palette, 256)
+	for i := range r {
+		r[i] = color.Black
+		if i >= len(v.Children) || len(v.Children[i].Children) != 1 {
+			continue
+		}
+		c := &v.Children[i].Children[0]
+		ch := func(name string) uint8 {
+			return uint8(imageIntField(c, name))
+		}
+		switch c.Type {
+		case "image/color.RGBA", "color.RGBA":
+			r[i] = color.RGBA{ch("R"), ch("G"), ch("B"), ch("A")}
+		case "image/color.NRGBA", "color.NRGBA":
+			r[i] = color.NRGBA{ch("R"), ch("G"), ch("B"), ch("A")}
+		case "image/color.Gray", "color.Gray":
+			r[i] = color.Gray{ch("Y")}
+		}
+	}
+	return r
+}
+
+// decode builds a local copy of the image and scales it, must be called
+// with iv.mu held.
+func (iv *imageViewer) decode() {
+	switch iv.typ {
+	case "*image.RGBA":
+		iv.img = &image.RGBA{Pix: iv.bufs[0], Stride: iv.stride, Rect: iv.rect}
+	case "*image.NRGBA":
+		iv.img = &image.NRGBA{Pix: iv.bufs[0], Stride: iv.stride, Rect: iv.rect}
+	case "*image.Gray":
+		iv.img = &image.Gray{Pix: iv.bufs[0], Stride: iv.stride, Rect: iv.rect}
+	case "*image.Paletted":
+		if iv.palette == nil {
+			iv.palette = imagePalette(&api.Variable{})
+		}
+		iv.img = &image.Paletted{Pix: iv.bufs[0], Stride: iv.stride, Rect: iv.rect, Palette: iv.palette}
+	case "*image.YCbCr":
+		iv.img = &image.YCbCr{Y: iv.bufs[0], Cb: iv.bufs[1], Cr: iv.bufs[2], YStride: iv.stride, CStride: iv.cstride, SubsampleRatio: iv.subsample, Rect: iv.rect}
+	}
+	iv.scale()
+}
+
+// scale draws the image at the current zoom level, using nearest neighbour
+// interpolation so that individual pixels remain visible.
+func (iv *imageViewer) scale() {
+	iv.scaled = scaleImage(iv.img, imageZoomLevels[iv.zoom])
+}
+
+func scaleImage(img image.Image, zoom int) *image.RGBA {
+	if img == nil {
+		return nil
+	}
+	b := img.Bounds()
+	sw, sh := scaledImageSize(b.Dx(), b.Dy(), zoom)
+	if sw*sh > maxScaledImageSz {
+		return nil
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	for y := 0; y < sh; y++ {
+		sy := b.Min.Y + y*b.Dy()/sh
+		for x := 0; x < sw; x++ {
+			dst.Set(x, y, img.At(b.Min.X+x*b.Dx()/sw, sy))
+		}
+	}
+	return dst
+}
+
+func scaledImageSize(w, h, zoom int) (int, int) {
+	if zoom > 0 {
+		w, h = w*zoom, h*zoom
+	} else {
+		w, h = w/-zoom, h/-zoom
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+	return w, h
+}
+
+func formatImagePixel(img image.Image, x, y int) string {
+	c := img.At(x, y)
+	s := fmt.Sprintf("(%d, %d) %T%v", x, y, c, c)
+	if p, ok := img.(*image.Paletted); ok {
+		s += fmt.Sprintf(" index %d", p.ColorIndexAt(x, y))
+	}
+	return s
+}
+
+func (iv *imageViewer) Update(w *nucular.Window) {
+	iv.mu.Lock()
+	defer iv.mu.Unlock()
+
+	w.Row(20).Static(60, 100, 0)
+	w.Label("Zoom:", "LC")
+	if zoom := w.ComboSimple(imageZoomNames, iv.zoom, 20); zoom != iv.zoom {
+		iv.zoom = zoom
+		iv.scale()
+	}
+	switch {
+	case iv.err != nil:
+		w.LabelColored(iv.err.Error(), "LC", errorColor)
+	case iv.total == 0:
+		w.Label("Loading...", "LC")
+	default:
+		w.Label(fmt.Sprintf("%s %dx%d (loaded: %d/%d bytes)", iv.typ, iv.rect.Dx(), iv.rect.Dy(), iv.loaded, iv.total), "LC")
+	}
+
+	w.Row(20).Dynamic(1)
+	w.Label(iv.hover, "LC")
+
+	w.Row(0).Dynamic(1)
+	if w := w.GroupBegin("image", 0); w != nil {
+		if iv.img != nil && iv.scaled == nil {
+			w.Row(20).Dynamic(1)
+			w.Label("Image too large at this zoom level", "LC")
+		}
+		if iv.scaled != nil {
+			sz := iv.scaled.Bounds().Size()
+			w.RowScaled(sz.Y).StaticScaled(sz.X)
+			w.Image(iv.scaled)
+			bounds := w.LastWidgetBounds
+			if w.Input().Mouse.HoveringRect(bounds) {
+				pos := w.Input().Mouse.Pos
+				b := iv.img.Bounds()
+				x := b.Min.X + (pos.X-bounds.X)*b.Dx()/sz.X
+				y := b.Min.Y + (pos.Y-bounds.Y)*b.Dy()/sz.Y
+				if (image.Point{x, y}).In(b) {
+					iv.hover = formatImagePixel(iv.img, x, y)
+					w.Tooltip(iv.hover)
+					if w.Input().Mouse.AnyClickInRect(bounds) {
+						clipboard.Set(iv.hover)
+					}
+				}
+			}
+		}
+		w.GroupEnd()
+	}
+}
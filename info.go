@@ -4,12 +4,14 @@ package main
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
@@ -144,6 +146,7 @@ var stackPanel = struct {
 	id           int
 	deferID      int
 	mode         int
+	deferArgs    map[deferredCallKey]*deferredCallArgs
 }{
 	depth: 50,
 }
@@ -155,12 +158,24 @@ var threadsPanel = struct {
 }{}
 
 var regsPanel = struct {
-	asyncLoad asyncLoad
-	regs      string
-	lines     int
-	allRegs   bool
-	width     int
-}{}
+	asyncLoad    asyncLoad
+	regs         api.Registers
+	changed      map[string]bool
+	allRegs      bool
+	vectorFormat int
+
+	prev       map[string]string // register values at the previous stop
+	prevThread int               // thread of prev
+	stop       map[string]string // register values at the current stop
+	stopThread int               // thread of stop
+	stopped    bool              // the target stopped since the registers were last loaded
+
+	id      int
+	editing string // register being edited
+	ed      nucular.TextEditor
+}{
+	ed: nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditSigEnter | nucular.EditClipboard},
+}
 
 var breakpointsPanel = struct {
 	asyncLoad   asyncLoad
@@ -190,9 +205,24 @@ var checkpointsPanel = struct {
 }{}
 
 var disassemblyPanel = struct {
-	asyncLoad asyncLoad
-	loc       api.Location
-}{}
+	asyncLoad   asyncLoad
+	loc         api.Location
+	interleaved bool
+	sources     map[string][]string
+	collapsed   map[uint64]bool // source line groups collapsed in interleaved mode, by PC of the first instruction
+
+	searchEd  nucular.TextEditor
+	searchRx  *regexp.Regexp
+	searchErr error
+
+	symbols       map[uint64]string // cache of symbols for immediate addresses
+	globals       []api.Variable    // package variables sorted by address
+	globalsLoaded bool              // globals has been loaded
+	globalSizes   map[string]int64  // sizes of the types of package variables
+}{
+	collapsed: map[uint64]bool{},
+	searchEd:  nucular.TextEditor{Flags: nucular.EditSelectable | nucular.EditSigEnter | nucular.EditClipboard},
+}
 
 func init() {
 	goroutinesPanel.asyncLoad.load = loadGoroutines
@@ -457,6 +487,7 @@ func loadStacktrace(p *asyncLoad) {
 	}
 
 	stackPanel.ancestors = stackPanel.ancestors[:0]
+	stackPanel.deferArgs = map[deferredCallKey]*deferredCallArgs{}
 
 	if err != nil {
 		p.done(err)
@@ -622,22 +653,39 @@ func updateThreads(container *nucular.Window) {
 }
 
 func loadRegs(p *asyncLoad) {
+	regsPanel.id++
 	regs, err := client.ListRegisters(0, regsPanel.allRegs)
-	regsPanel.regs = expandTabs(regs.String())
-	regsPanel.lines = 1
-	lineStart := 0
-	maxline := 0
-	for i := range regsPanel.regs {
-		if regsPanel.regs[i] == '\n' {
-			if lw := i - lineStart; lw > maxline {
-				maxline = lw
+	if err != nil {
+		p.done(err)
+		return
+	}
+
+	if regsPanel.stopped {
+		// the registers are only snapshotted when the target stops, switching
+		// goroutine or frame or loading all registers keeps the snapshots.
+		regsPanel.stopped = false
+		regsPanel.prev, regsPanel.prevThread = regsPanel.stop, regsPanel.stopThread
+		regsPanel.stop, regsPanel.stopThread = map[string]string{}, curThread
+	}
+	if regsPanel.stopThread == curThread {
+		for _, reg := range regs {
+			if _, ok := regsPanel.stop[reg.Name]; !ok {
+				regsPanel.stop[reg.Name] = reg.Value
 			}
-			lineStart = i + 1
-			regsPanel.lines++
 		}
 	}
-	regsPanel.width = zeroWidth * maxline
-	p.done(err)
+
+	// changes are only meaningful when comparing registers of the same thread
+	regsPanel.changed = map[string]bool{}
+	if regsPanel.prevThread == curThread {
+		for _, reg := range regs {
+			if old, ok := regsPanel.prev[reg.Name]; ok && old != reg.Value {
+				regsPanel.changed[reg.Name] = true
+			}
+		}
+	}
+	regsPanel.regs = regs
+	p.done(nil)
 }
 
 func replaceRegs(in string) string {
@@ -665,14 +713,73 @@ func updateRegs(container *nucular.Window) {
 	}
 
 	w.MenubarBegin()
-	w.Row(varRowHeight).Static(100)
+	w.Row(varRowHeight).Static(100, 100, 100)
 	if w.CheckboxText("Show All", &regsPanel.allRegs) {
-		loadRegs(&regsPanel.asyncLoad)
+		go func() {
+			regsPanel.asyncLoad.clear()
+			wnd.Changed()
+		}()
 	}
+	w.Label("Vector lanes:", "LC")
+	regsPanel.vectorFormat = w.ComboSimple(vectorFormats, regsPanel.vectorFormat, 20)
 	w.MenubarEnd()
 
-	w.Row(20 * regsPanel.lines).Static(regsPanel.width)
-	w.Label(regsPanel.regs, "LT")
+	style := w.Master().Style()
+	descrColor := style.Text.Color
+	darken(&descrColor)
+
+	for _, reg := range regsPanel.regs {
+		value, descr := describeRegister(reg.Name, reg.Value)
+		w.Row(posRowHeight).Static()
+		w.LayoutFitWidth(regsPanel.id, 1)
+		w.Label(reg.Name, "RC")
+		if regsPanel.editing == reg.Name {
+			w.LayoutSetWidth(300)
+			if active := regsPanel.ed.Edit(w); active&nucular.EditCommitted != 0 {
+				regsPanel.editing = ""
+				if newval := strings.TrimSpace(string(regsPanel.ed.Buffer)); newval != "" && newval != value {
+					go func(reg string) {
+						if err := setRegister("$"+strings.ToLower(reg), newval); err != nil {
+							out := editorWriter{true}
+							fmt.Fprintf(&out, "Could not set register %s: %v\n", reg, err)
+						}
+					}(reg.Name)
+				}
+			}
+			continue
+		}
+		w.LayoutFitWidth(regsPanel.id, 1)
+		if regsPanel.changed[reg.Name] {
+			w.LabelColored(value, "LC", changedRegisterColor)
+		} else {
+			w.Label(value, "LC")
+		}
+		if w.Input().Mouse.HoveringRect(w.LastWidgetBounds) {
+			w.Tooltip("Click to copy, right click to edit")
+			if w.Input().Mouse.IsClickInRect(mouse.ButtonLeft, w.LastWidgetBounds) {
+				clipboard.Set(value)
+			}
+		}
+		if w := w.ContextualOpen(0, image.Point{}, w.LastWidgetBounds, nil); w != nil {
+			w.Row(20).Dynamic(1)
+			if w.MenuItem(label.TA("Copy value", "LC")) {
+				clipboard.Set(value)
+			}
+			if w.MenuItem(label.TA("Set value...", "LC")) {
+				regsPanel.editing = reg.Name
+				regsPanel.ed.Buffer = []rune(value)
+				regsPanel.ed.Cursor = len(regsPanel.ed.Buffer)
+				regsPanel.ed.SelectStart = 0
+				regsPanel.ed.SelectEnd = regsPanel.ed.Cursor
+				regsPanel.ed.CursorFollow = true
+				regsPanel.ed.Active = true
+			}
+		}
+		if descr != "" {
+			w.LayoutFitWidth(regsPanel.id, 1)
+			w.LabelColored(descr, "LC", descrColor)
+		}
+	}
 }
 
 type breakpointsByID []*api.Breakpoint
@@ -1110,6 +1217,29 @@ func updateDeferredCalls(container *nucular.Window) {
 			curDeferredCall = i + 1
 			go refreshState(refreshToSameFrame, clearFrameSwitch, nil)
 		}
+
+		if deferredCall.Unreadable != "" {
+			continue
+		}
+
+		if w := w.ContextualOpen(0, image.Point{}, w.LastWidgetBounds, nil); w != nil {
+			w.Row(20).Dynamic(1)
+			if deferredCall.DeferredLoc.Function != nil {
+				if w.MenuItem(label.TA("Step into when run", "LC")) {
+					go functionListSetBreakpoint(deferredCall.DeferredLoc.Function.Name())
+				}
+			}
+			if w.MenuItem(label.TA("Evaluate in deferred call scope", "LC")) {
+				curDeferredCall = i + 1
+				go refreshState(refreshToSameFrame, clearFrameSwitch, nil)
+			}
+		}
+
+		w.Row(varRowHeight).Dynamic(1)
+		if w.TreePushNamed(nucular.TreeNode, fmt.Sprintf("defer%d", i), "Arguments", false) {
+			showDeferredCallArgs(w, curFrame, i+1)
+			w.TreePop()
+		}
 	}
 }
 
@@ -1418,6 +1548,8 @@ func updateDisassemblyPanel(container *nucular.Window) {
 
 	const lineheight = 14
 
+	disassemblyToolbar(container)
+
 	container.Row(0).Dynamic(1)
 
 	gl, listp := nucular.GroupListStart(container, len(listingPanel.text), "disassembly", 0)
@@ -1444,6 +1576,9 @@ func updateDisassemblyPanel(container *nucular.Window) {
 	unreachableColor := style.Text.Color
 	darken(&unreachableColor)
 
+	collapsed := false
+	prevInstr := jumpArrowsRow{idx: -1}
+
 	for gl.Next() {
 		instr := listingPanel.text[gl.Index()]
 
@@ -1453,7 +1588,15 @@ func updateDisassemblyPanel(container *nucular.Window) {
 			style.Text.Color = reachableColor
 		}
 
-		if instr.Loc.File != lastfile || instr.Loc.Line != lastlineno {
+		if disassemblyPanel.interleaved {
+			if instr.Loc.File != lastfile || instr.Loc.Line != lastlineno {
+				collapsed = disassemblySourceGroup(listp, gl.Index(), lineheight)
+				lastfile, lastlineno = instr.Loc.File, instr.Loc.Line
+			}
+			if collapsed {
+				continue
+			}
+		} else if instr.Loc.File != lastfile || instr.Loc.Line != lastlineno {
 			if instr.Loc.File != listingPanel.file || strings.ToLower(filepath.Ext(listingPanel.file)) != ".s" {
 				listp.Row(lineheight).Static()
 				listp.Row(lineheight).Static()
@@ -1461,6 +1604,7 @@ func updateDisassemblyPanel(container *nucular.Window) {
 				if instr.Loc.File == listingPanel.file && instr.Loc.Line-1 < len(listingPanel.listing) && instr.Loc.Line-1 > 0 {
 					text = strings.TrimSpace(listingPanel.listing[instr.Loc.Line-1].text)
 				}
+				disassemblyGutterSpacing(listp)
 				listp.LayoutFitWidth(listingPanel.id, 1)
 				listp.Label(fmt.Sprintf("%s:%d: %s", instr.Loc.File, instr.Loc.Line, text), "LC")
 			}
@@ -1468,6 +1612,8 @@ func updateDisassemblyPanel(container *nucular.Window) {
 		}
 		listp.Row(lineheight).Static()
 
+		gutterBounds := disassemblyGutterSpacing(listp)
+
 		listp.LayoutSetWidthScaled(starw)
 
 		centerline := instr.AtPC || instr.Loc.PC == listingPanel.framePC
@@ -1494,6 +1640,13 @@ func updateDisassemblyPanel(container *nucular.Window) {
 			cmds.FillRect(rowbounds, 0, c)
 		}
 
+		if disassemblyPanel.searchRx != nil && disassemblyPanel.searchRx.MatchString(instr.Text) && gl.Index() != listingPanel.disassHoverClickIdx {
+			rowbounds := listp.WidgetBounds()
+			rowbounds.X = listp.Bounds.X
+			rowbounds.W = listp.Bounds.W
+			listp.Commands().FillRect(rowbounds, 0, style.Selectable.HoverActive.Data.Color)
+		}
+
 		breakpointIcon(listp, instr.Breakpoint, true, "CC", style)
 
 		listp.LayoutSetWidth(arroww)
@@ -1511,13 +1664,23 @@ func updateDisassemblyPanel(container *nucular.Window) {
 		listp.LayoutFitWidth(listingPanel.id, 100)
 		listp.Label(instr.op, "LC")
 		listp.LayoutFitWidth(listingPanel.id, 100)
-		listp.Label(instr.args, "LC")
+		if instr.dstidx >= 0 {
+			listp.LabelColored(instr.args, "LC", linkColor)
+		} else {
+			listp.Label(instr.args, "LC")
+		}
+		argsBounds := listp.LastWidgetBounds
+		if instr.sym != "" {
+			c := style.Text.Color
+			darken(&c)
+			listp.LayoutFitWidth(listingPanel.id, 100)
+			listp.LabelColored(instr.sym, "LC", c)
+		}
 
-		if listp.Input().Mouse.HoveringRect(listp.LastWidgetBounds) {
+		if listp.Input().Mouse.HoveringRect(argsBounds) {
 			if instr.dstidx >= 0 {
-				if listp.Input().Mouse.IsClickInRect(mouse.ButtonLeft, listp.LastWidgetBounds) {
-					listingPanel.disassHoverClickIdx = instr.dstidx
-					listingPanel.centerOnDisassHover = true
+				if listp.Input().Mouse.IsClickInRect(mouse.ButtonLeft, argsBounds) {
+					disassemblyFollowJump(gl.Index(), instr.dstidx)
 				}
 				if listingPanel.disassHoverIdx != instr.dstidx {
 					listingPanel.disassHoverIdx = instr.dstidx
@@ -1525,6 +1688,8 @@ func updateDisassemblyPanel(container *nucular.Window) {
 			}
 		}
 
+		drawJumpArrows(listp, gl.Index(), gutterBounds, &prevInstr)
+
 		if listingPanel.recenterDisassembly && centerline {
 			listingPanel.recenterDisassembly = false
 			gl.Center()
@@ -1538,6 +1703,434 @@ func updateDisassemblyPanel(container *nucular.Window) {
 	}
 }
 
+func disassemblyToolbar(container *nucular.Window) {
+	container.Row(headerRow).Static(200, 80, 80, 60, 200, 0)
+	if container.CheckboxText("Interleave source", &disassemblyPanel.interleaved) {
+		go func() {
+			disassemblyPanel.asyncLoad.clear()
+			wnd.Changed()
+		}()
+	}
+	if container.ButtonText("Back") && listingPanel.disassHistoryPos > 0 {
+		listingPanel.disassHistoryPos--
+		disassemblyGoto(listingPanel.disassHistory[listingPanel.disassHistoryPos])
+	}
+	if container.ButtonText("Forward") && listingPanel.disassHistoryPos < len(listingPanel.disassHistory)-1 {
+		listingPanel.disassHistoryPos++
+		disassemblyGoto(listingPanel.disassHistory[listingPanel.disassHistoryPos])
+	}
+
+	container.Label("Search:", "RC")
+	if active := disassemblyPanel.searchEd.Edit(container); active&nucular.EditCommitted != 0 {
+		disassemblySearch(string(disassemblyPanel.searchEd.Buffer))
+	}
+	if disassemblyPanel.searchErr != nil {
+		container.LabelColored(disassemblyPanel.searchErr.Error(), "LC", errorColor)
+	} else {
+		container.Spacing(1)
+	}
+}
+
+// disassemblySearch scrolls the disassembly panel to the next instruction
+// matching the regular expression needle.
+func disassemblySearch(needle string) {
+	disassemblyPanel.searchErr = nil
+	if needle == "" {
+		disassemblyPanel.searchRx = nil
+		return
+	}
+	if disassemblyPanel.searchRx == nil || disassemblyPanel.searchRx.String() != needle {
+		rx, err := regexp.Compile(needle)
+		if err != nil {
+			disassemblyPanel.searchRx = nil
+			disassemblyPanel.searchErr = err
+			return
+		}
+		disassemblyPanel.searchRx = rx
+	}
+
+	start := listingPanel.disassHoverClickIdx + 1
+	for i := range listingPanel.text {
+		idx := (start + i) % len(listingPanel.text)
+		if disassemblyPanel.searchRx.MatchString(listingPanel.text[idx].Text) {
+			disassemblyGoto(listingPanel.text[idx].Loc.PC)
+			return
+		}
+	}
+	disassemblyPanel.searchErr = errors.New("not found")
+}
+
+// disassemblyFollowJump scrolls the disassembly panel to instruction dst,
+// recording the jump from instruction src in the navigation history.
+func disassemblyFollowJump(src, dst int) {
+	h := listingPanel.disassHistory
+	if listingPanel.disassHistoryPos+1 < len(h) {
+		h = h[:listingPanel.disassHistoryPos+1]
+	}
+	srcpc := listingPanel.text[src].Loc.PC
+	if len(h) == 0 || h[len(h)-1] != srcpc {
+		h = append(h, srcpc)
+	}
+	h = append(h, listingPanel.text[dst].Loc.PC)
+	listingPanel.disassHistory = h
+	listingPanel.disassHistoryPos = len(h) - 1
+	disassemblyGoto(listingPanel.text[dst].Loc.PC)
+}
+
+// disassemblyGoto scrolls the disassembly panel to the instruction at pc,
+// expanding its source line group if it was collapsed.
+func disassemblyGoto(pc uint64) {
+	idx := findInstruction(pc)
+	if idx < 0 {
+		return
+	}
+	start := idx
+	for start > 0 && listingPanel.text[start-1].Loc.File == listingPanel.text[idx].Loc.File && listingPanel.text[start-1].Loc.Line == listingPanel.text[idx].Loc.Line {
+		start--
+	}
+	delete(disassemblyPanel.collapsed, listingPanel.text[start].Loc.PC)
+	listingPanel.disassHoverClickIdx = idx
+	listingPanel.centerOnDisassHover = true
+}
+
+func findInstruction(pc uint64) int {
+	for i := range listingPanel.text {
+		if listingPanel.text[i].Loc.PC == pc {
+			return i
+		}
+	}
+	return -1
+}
+
+// disassemblyJump is a jump between two instructions of the disassembly
+// panel, lane is the column of the gutter used to draw its arrow.
+type disassemblyJump struct {
+	src, dst int
+	lane     int
+}
+
+const (
+	maxJumpLanes   = 8
+	jumpLaneWidth  = 6
+	jumpArrowWidth = 5
+)
+
+// disassemblyJumps returns the list of jumps between instructions in text
+// and the number of gutter lanes needed to draw them. Shorter jumps are
+// placed on the lanes closest to the instructions, jumps that overlap are
+// assigned to different lanes, up to maxJumpLanes.
+func disassemblyJumps(text []wrappedInstruction) ([]disassemblyJump, int) {
+	r := []disassemblyJump{}
+	for i := range text {
+		if text[i].dstidx >= 0 && text[i].dstidx != i {
+			r = append(r, disassemblyJump{src: i, dst: text[i].dstidx})
+		}
+	}
+
+	span := func(j disassemblyJump) (int, int) {
+		if j.src < j.dst {
+			return j.src, j.dst
+		}
+		return j.dst, j.src
+	}
+
+	sort.SliceStable(r, func(i, j int) bool {
+		lo1, hi1 := span(r[i])
+		lo2, hi2 := span(r[j])
+		return hi1-lo1 < hi2-lo2
+	})
+
+	lanes := 0
+	for i := range r {
+		lo, hi := span(r[i])
+		lane := 0
+		for ; lane < maxJumpLanes-1; lane++ {
+			free := true
+			for j := 0; j < i; j++ {
+				if r[j].lane != lane {
+					continue
+				}
+				lo2, hi2 := span(r[j])
+				if lo <= hi2 && lo2 <= hi {
+					free = false
+					break
+				}
+			}
+			if free {
+				break
+			}
+		}
+		r[i].lane = lane
+		if lane+1 > lanes {
+			lanes = lane + 1
+		}
+	}
+
+	return r, lanes
+}
+
+// disassemblyGutterSpacing reserves space for jump arrows at the start of
+// a row of the disassembly panel and returns its bounds.
+func disassemblyGutterSpacing(listp *nucular.Window) rect.Rect {
+	if listingPanel.jumpLanes == 0 {
+		return rect.Rect{}
+	}
+	listp.LayoutSetWidth((listingPanel.jumpLanes+1)*jumpLaneWidth + jumpArrowWidth)
+	bounds := listp.WidgetBounds()
+	listp.Spacing(1)
+	return bounds
+}
+
+type jumpArrowsRow struct {
+	idx int
+	y   int
+}
+
+// drawJumpArrows draws the portion of jump arrows that cross the row of
+// instruction idx, prev is the last instruction row drawn and is updated.
+func drawJumpArrows(listp *nucular.Window, idx int, gutter rect.Rect, prev *jumpArrowsRow) {
+	if listingPanel.jumpLanes == 0 {
+		return
+	}
+	style := listp.Master().Style()
+	cmds := listp.Commands()
+	y := gutter.Y + gutter.H/2
+	scale := func(x int) int { return int(float64(x) * style.Scaling) }
+	right := gutter.X + gutter.W
+
+	visible := !(y < listp.Bounds.Y && prev.y < listp.Bounds.Y) && !(prev.y > listp.Bounds.Y+listp.Bounds.H && y > listp.Bounds.Y+listp.Bounds.H)
+
+	defer func() {
+		prev.idx = idx
+		prev.y = y
+	}()
+
+	if !visible {
+		return
+	}
+
+	for _, j := range listingPanel.jumps {
+		lo, hi := j.src, j.dst
+		if lo > hi {
+			lo, hi = hi, lo
+		}
+		if idx < lo || idx > hi {
+			continue
+		}
+		c := style.Text.Color
+		if j.src == listingPanel.disassHoverIdx || j.dst == listingPanel.disassHoverIdx {
+			c = linkColor
+		} else {
+			darken(&c)
+		}
+		x := right - scale(jumpArrowWidth+(j.lane+1)*jumpLaneWidth)
+		if idx > lo && prev.idx >= lo {
+			cmds.StrokeLine(image.Point{x, prev.y}, image.Point{x, y}, 1, c)
+		}
+		switch idx {
+		case j.src:
+			cmds.StrokeLine(image.Point{x, y}, image.Point{right, y}, 1, c)
+		case j.dst:
+			aw := scale(jumpArrowWidth)
+			cmds.StrokeLine(image.Point{x, y}, image.Point{right - aw, y}, 1, c)
+			cmds.FillTriangle(image.Point{right - aw, y - aw/2}, image.Point{right - aw, y + aw/2}, image.Point{right, y}, c)
+		}
+	}
+}
+
+// disassemblySourceGroup draws the header of a group of instructions
+// belonging to the same source line, starting at instruction idx, for the
+// interleaved disassembly view. Clicking the header collapses or expands
+// the group, returns true if the group is collapsed.
+func disassemblySourceGroup(listp *nucular.Window, idx int, lineheight int) bool {
+	style := listp.Master().Style()
+	first := listingPanel.text[idx]
+
+	containsPC := false
+	for i := idx; i < len(listingPanel.text); i++ {
+		instr := &listingPanel.text[i]
+		if instr.Loc.File != first.Loc.File || instr.Loc.Line != first.Loc.Line {
+			break
+		}
+		if instr.AtPC || instr.Loc.PC == listingPanel.framePC {
+			containsPC = true
+		}
+	}
+
+	collapsed := disassemblyPanel.collapsed[first.Loc.PC]
+
+	listp.Row(lineheight).Static()
+	disassemblyGutterSpacing(listp)
+	rowbounds := listp.WidgetBounds()
+	rowbounds.X = listp.Bounds.X
+	rowbounds.W = listp.Bounds.W
+
+	if collapsed && containsPC {
+		listp.Commands().FillRect(rowbounds, 0, style.Selectable.PressedActive.Data.Color)
+	}
+
+	listp.LayoutSetWidthScaled(starWidth + style.Text.Padding.X*2)
+	iconFace, style.Font = style.Font, iconFace
+	if collapsed {
+		listp.Label(caretRightIconChar, "CC")
+	} else {
+		listp.Label(caretDownIconChar, "CC")
+	}
+	iconFace, style.Font = style.Font, iconFace
+
+	text := ""
+	if lines := disassemblyPanel.sources[first.Loc.File]; first.Loc.Line-1 >= 0 && first.Loc.Line-1 < len(lines) {
+		text = lines[first.Loc.Line-1]
+	}
+	listp.LayoutFitWidth(listingPanel.id, 1)
+	listp.Label(fmt.Sprintf("%s:%d: %s", ShortenFilePath(first.Loc.File), first.Loc.Line, text), "LC")
+
+	if listp.Input().Mouse.IsClickInRect(mouse.ButtonLeft, rowbounds) {
+		collapsed = !collapsed
+		disassemblyPanel.collapsed[first.Loc.PC] = collapsed
+		listp.Master().Changed()
+	}
+
+	return collapsed
+}
+
+var immediateRx = regexp.MustCompile(`\b0x[0-9a-fA-F]+\b`)
+
+// minSymbolAddress is the smallest immediate value that is looked up as a
+// symbol, smaller values are almost certainly not addresses.
+const minSymbolAddress = 0x1000
+
+// maxSymbolLookups is the maximum number of addresses and type sizes that
+// will be looked up on the target every time a disassembly is loaded.
+const maxSymbolLookups = 32
+
+// symbolizeInstructions annotates the instructions in text that have an
+// immediate argument pointing to a function or package variable.
+func symbolizeInstructions(text []wrappedInstruction) {
+	if disassemblyPanel.symbols == nil {
+		disassemblyPanel.symbols = map[uint64]string{}
+		disassemblyPanel.globalSizes = map[string]int64{}
+	}
+
+	// collect all the addresses first so that each one is looked up at most
+	// once and the number of lookups on the target can be bounded.
+	addrs := make([][]uint64, len(text))
+	var missing []uint64
+	seen := map[uint64]bool{}
+	for i := range text {
+		if text[i].dstidx >= 0 {
+			continue
+		}
+		for _, m := range immediateRx.FindAllString(text[i].args, -1) {
+			addr, err := strconv.ParseUint(m, 0, 64)
+			if err != nil || addr < minSymbolAddress {
+				continue
+			}
+			addrs[i] = append(addrs[i], addr)
+			if _, ok := disassemblyPanel.symbols[addr]; !ok && !seen[addr] {
+				seen[addr] = true
+				missing = append(missing, addr)
+			}
+		}
+	}
+
+	if len(missing) > 0 && !disassemblyPanel.globalsLoaded {
+		// package variables are only listed the first time an address needs
+		// to be symbolized
+		globals, _ := client.ListPackageVariables("", api.LoadConfig{})
+		disassemblyPanel.globals = sortGlobalsByAddress(globals)
+		disassemblyPanel.globalsLoaded = true
+	}
+
+	// looking up the size of a type counts against the same limit as
+	// looking up a function
+	lookups := 0
+	sizeof := func(typ string) int64 {
+		if size, ok := disassemblyPanel.globalSizes[typ]; ok {
+			return size
+		}
+		if lookups >= maxSymbolLookups {
+			return 0
+		}
+		lookups++
+		return globalTypeSize(typ)
+	}
+
+	for _, addr := range missing {
+		if sym := findGlobalSymbol(disassemblyPanel.globals, addr, sizeof); sym != "" {
+			disassemblyPanel.symbols[addr] = sym
+			continue
+		}
+		if lookups >= maxSymbolLookups {
+			continue
+		}
+		lookups++
+		sym := ""
+		locs, err := client.FindLocation(currentEvalScope(), fmt.Sprintf("*%#x", addr), false)
+		if err == nil && len(locs) == 1 && locs[0].Function != nil && addr >= locs[0].Function.Value {
+			sym = formatSymbol(locs[0].Function.Name(), addr-locs[0].Function.Value)
+		}
+		disassemblyPanel.symbols[addr] = sym
+	}
+
+	for i := range text {
+		for _, addr := range addrs[i] {
+			if sym := disassemblyPanel.symbols[addr]; sym != "" {
+				text[i].sym = "<" + sym + ">"
+				break
+			}
+		}
+	}
+}
+
+// globalTypeSize returns the size of the type called typ, caching the
+// result. Returns 0 if the size could not be determined.
+func globalTypeSize(typ string) int64 {
+	if size, ok := disassemblyPanel.globalSizes[typ]; ok {
+		return size
+	}
+	size, _ := client.TypeSize(currentEvalScope(), typ)
+	disassemblyPanel.globalSizes[typ] = size
+	return size
+}
+
+func sortGlobalsByAddress(globals []api.Variable) []api.Variable {
+	r := make([]api.Variable, 0, len(globals))
+	for i := range globals {
+		if globals[i].Addr != 0 {
+			r = append(r, globals[i])
+		}
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Addr < r[j].Addr })
+	return r
+}
+
+// findGlobalSymbol returns the name of the package variable containing
+// addr, globals must be sorted by address. The size of each variable is
+// determined by calling sizeof with its type, variables of unknown size
+// only match their starting address.
+func findGlobalSymbol(globals []api.Variable, addr uint64, sizeof func(typ string) int64) string {
+	i := sort.Search(len(globals), func(i int) bool { return globals[i].Addr > uintptr(addr) }) - 1
+	if i < 0 {
+		return ""
+	}
+	off := addr - uint64(globals[i].Addr)
+	if off != 0 {
+		size := sizeof(globals[i].Type)
+		if size <= 0 || off >= uint64(size) {
+			return ""
+		}
+	}
+	return formatSymbol(globals[i].Name, off)
+}
+
+func formatSymbol(name string, off uint64) string {
+	if off == 0 {
+		return name
+	}
+	return fmt.Sprintf("%s+%#x", name, off)
+}
+
 type wrappedInstruction struct {
 	api.AsmInstruction
 
@@ -1545,6 +2138,7 @@ type wrappedInstruction struct {
 	args   string
 	reach  bool
 	dstidx int
+	sym    string
 }
 
 var asmprefixes = map[string]bool{
@@ -1648,3 +2242,75 @@ func wrapInstructions(text api.AsmInstructions, curpc uint64) []wrappedInstructi
 
 	return r
 }
+
+// scopeVariableExpressions prepends prefix, a scope specifier, to the
+// expressions of vs and their children.
+func scopeVariableExpressions(vs []*Variable, prefix string) {
+	for _, v := range vs {
+		if v == nil {
+			continue
+		}
+		if v.Expression != "" {
+			v.Expression = prefix + v.Expression
+		}
+		scopeVariableExpressions(v.Children, prefix)
+	}
+}
+
+type deferredCallKey struct {
+	frame, deferredCall int
+}
+
+type deferredCallArgs struct {
+	loading bool
+	vars    []*Variable
+	err     error
+}
+
+// showDeferredCallArgs displays the arguments and captured variables of
+// the specified deferred call, evaluated using the deferred call as the
+// scope. Arguments are loaded the first time they are shown.
+func showDeferredCallArgs(w *nucular.Window, frame, deferredCall int) {
+	k := deferredCallKey{frame, deferredCall}
+	args := stackPanel.deferArgs[k]
+	if args == nil {
+		args = &deferredCallArgs{loading: true}
+		stackPanel.deferArgs[k] = args
+		go loadDeferredCallArgs(args, api.EvalScope{GoroutineID: curGid, Frame: frame, DeferredCall: deferredCall})
+	}
+
+	switch {
+	case args.loading:
+		w.Row(varRowHeight).Dynamic(1)
+		w.Label("Loading...", "LC")
+	case args.err != nil:
+		w.Row(varRowHeight).Dynamic(1)
+		w.Label(fmt.Sprintf("Error: %v", args.err), "LC")
+	case len(args.vars) == 0:
+		w.Row(varRowHeight).Dynamic(1)
+		w.Label("(no arguments)", "LC")
+	default:
+		for _, v := range args.vars {
+			showVariable(w, 0, false, false, nil, -1, v)
+		}
+	}
+}
+
+func loadDeferredCallArgs(args *deferredCallArgs, scope api.EvalScope) {
+	vars, err := client.ListFunctionArgs(scope, getVariableLoadConfig())
+	if err == nil {
+		var locals []api.Variable
+		// closure variables are reported as locals of the deferred function
+		locals, err = client.ListLocalVariables(scope, getVariableLoadConfig())
+		vars = append(vars, locals...)
+	}
+	wvars := loadVariables(vars, 0, 0, "")
+	// the variables only exist in the scope of the deferred call
+	scopeVariableExpressions(wvars, fmt.Sprintf("@g%df%dd%d ", scope.GoroutineID, scope.Frame, scope.DeferredCall))
+	wnd.Lock()
+	args.loading = false
+	args.err = err
+	args.vars = wvars
+	wnd.Unlock()
+	wnd.Changed()
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"image"
 	"image/color"
+	"io/ioutil"
 	"reflect"
 	"sort"
 	"strconv"
@@ -53,9 +55,51 @@ type Variable struct {
 
 	changed bool
 
+	scriptFmt *scriptFormatter // formatter applied by loadVariable and loadVariables
+	formatted bool             // Value was set by a custom or script formatter
+	mapKey    *api.Variable    // key of a map entry whose key is shown in DisplayName
+
 	Children []*Variable
 }
 
+// apiVariable returns the value of v as an api.Variable, including the
+// children that were loaded after v (or one of its descendants) was first
+// loaded.
+func (v *Variable) apiVariable() *api.Variable {
+	return v.toApiVariable(false)
+}
+
+// formattedApiVariable is like apiVariable but values that were formatted
+// by a custom or script formatter are replaced by their formatted value.
+func (v *Variable) formattedApiVariable() *api.Variable {
+	return v.toApiVariable(true)
+}
+
+func (v *Variable) toApiVariable(formatted bool) *api.Variable {
+	r := *v.Variable
+	r.Children = nil
+	if formatted && v.formatted {
+		// the kind is cleared so that the value is printed as is
+		r.Kind = reflect.Invalid
+		r.Value = v.Value
+		return &r
+	}
+	if v.Kind == reflect.Map {
+		for i := 0; i+1 < len(v.Children); i += 2 {
+			if v.Children[i+1] == nil {
+				r.Children = append(r.Children, *v.Children[i].mapKey, *v.Children[i].toApiVariable(formatted))
+			} else {
+				r.Children = append(r.Children, *v.Children[i].toApiVariable(formatted), *v.Children[i+1].toApiVariable(formatted))
+			}
+		}
+		return &r
+	}
+	for _, child := range v.Children {
+		r.Children = append(r.Children, *child.toApiVariable(formatted))
+	}
+	return &r
+}
+
 // SinglelineString returns a representation of v on a single line.
 func (v *Variable) SinglelineString(includeType, fullTypes bool) string {
 	return prettyprint.Singleline(v.Variable, includeType, fullTypes)
@@ -70,6 +114,23 @@ func wrapApiVariableSimple(v *api.Variable) *Variable {
 	return wrapApiVariable(v, v.Name, v.Name, false, 0)
 }
 
+// loadVariable wraps v, a variable just loaded from the target, formatting
+// it and its children. Formatters can be slow, this must be called by the
+// goroutines loading variables and never by the UI loop.
+func loadVariable(v *api.Variable, name, expr string) *Variable {
+	r := wrapApiVariable(v, name, expr, true, 0)
+	applyScriptFormatters(r)
+	return r
+}
+
+// loadVariables is like loadVariable for the children of a variable of
+// kind kind and expression expr, starting at index start.
+func loadVariables(vs []api.Variable, kind reflect.Kind, start int, expr string) []*Variable {
+	r := wrapApiVariables(vs, kind, start, expr, true, 0)
+	applyScriptFormatters(r...)
+	return r
+}
+
 func wrapApiVariable(v *api.Variable, name, expr string, customFormatters bool, depth int) *Variable {
 	r := &Variable{Variable: v}
 	r.Value = v.Value
@@ -104,6 +165,9 @@ func wrapApiVariable(v *api.Variable, name, expr string, customFormatters bool,
 		}
 	} else if f := conf.CustomFormatters[v.Type]; f != nil && customFormatters && depth < 10 {
 		f.Format(r)
+		r.formatted = true
+	} else if f := findScriptFormatter(v.Type); f != nil && customFormatters && depth < 10 {
+		r.scriptFmt = f
 	} else if v.Type == "time.Time" {
 		r.Value = formatTime(v)
 	}
@@ -178,7 +242,9 @@ func wrapApiVariables(vs []api.Variable, kind reflect.Kind, start int, expr stri
 				}
 				if keyname != "" {
 					value.Name = keyname[1 : len(keyname)-1]
-					r = append(r, wrapApiVariable(value, keyname, "", customFormatters, depth))
+					vw := wrapApiVariable(value, keyname, "", customFormatters, depth)
+					vw.mapKey = key
+					r = append(r, vw)
 					r = append(r, nil)
 					ok = true
 				}
@@ -243,14 +309,26 @@ var globalsPanel = struct {
 var localsPanel = struct {
 	asyncLoad    asyncLoad
 	filterEditor nucular.TextEditor
-	showAddr     bool
-	fullTypes    bool
-	locals       []*Variable
+	filterRegexp bool
+	filterExpand bool
+	filterLoads  int
+	lastFilter   string
+
+	filter            *variableFilter // nil if there is no filter
+	filterErr         error
+	filterDirty       bool // locals changed since filter was computed
+	filterLoadRunning bool // a load of additional children was running when filter was last checked
+
+	showAddr  bool
+	fullTypes bool
+	locals    []*Variable
 
 	expressions []Expr
 	selected    int
 	ed          nucular.TextEditor
 	v           []*Variable
+
+	historyLoc *api.Location // location of the last stop, if its values haven't been recorded yet
 }{
 	filterEditor: nucular.TextEditor{Filter: spacefilter},
 	selected:     -1,
@@ -261,12 +339,16 @@ type Expr struct {
 	Expr                         string
 	maxArrayValues, maxStringLen int
 	traced                       bool
+	cond                         string // the expression is only evaluated when cond is true
+	condFalse                    bool   // cond was false the last time the expression was loaded
+	condErr                      string // error evaluating cond the last time the expression was loaded
 	fmt                          formatterFn
+	history                      *exprHistory
 }
 
 func loadGlobals(p *asyncLoad) {
 	globals, err := client.ListPackageVariables("", getVariableLoadConfig())
-	globalsPanel.globals = wrapApiVariables(globals, 0, 0, "", true, 0)
+	globalsPanel.globals = loadVariables(globals, 0, 0, "")
 	sort.Sort(variablesByName(globalsPanel.globals))
 	p.done(err)
 }
@@ -293,7 +375,7 @@ func updateGlobals(container *nucular.Window) {
 
 	for i := range globals {
 		if strings.Index(globals[i].Name, filter) >= 0 {
-			showVariable(w, 0, globalsPanel.showAddr, globalsPanel.fullTypes, -1, globals[i])
+			showVariable(w, 0, globalsPanel.showAddr, globalsPanel.fullTypes, nil, -1, globals[i])
 		}
 	}
 }
@@ -311,9 +393,9 @@ func loadLocals(p *asyncLoad) {
 	drawStartTime = time.Now()
 
 	args, errloc := client.ListFunctionArgs(currentEvalScope(), getVariableLoadConfig())
-	localsPanel.locals = wrapApiVariables(args, 0, 0, "", true, 0)
+	localsPanel.locals = loadVariables(args, 0, 0, "")
 	locals, errarg := client.ListLocalVariables(currentEvalScope(), getVariableLoadConfig())
-	localsPanel.locals = append(localsPanel.locals, wrapApiVariables(locals, 0, 0, "", true, 0)...)
+	localsPanel.locals = append(localsPanel.locals, loadVariables(locals, 0, 0, "")...)
 
 	sort.SliceStable(localsPanel.locals, func(i, j int) bool { return localsPanel.locals[i].DeclLine < localsPanel.locals[j].DeclLine })
 
@@ -332,13 +414,15 @@ func loadLocals(p *asyncLoad) {
 	var scrollbackOut = editorWriter{true}
 	for i := range localsPanel.expressions {
 		loadOneExpr(i)
-		if localsPanel.expressions[i].traced {
+		if localsPanel.expressions[i].traced && !localsPanel.expressions[i].condFalse {
 			fmt.Fprintf(&scrollbackOut, "%s = %s\n", localsPanel.v[i].Name, localsPanel.v[i].SinglelineString(true, false))
 		}
 	}
 
 	markChangedVariables(localsPanel.v, oldv)
 
+	recordExprHistory()
+
 	if LogOutputNice != nil {
 		logf("Local variables (%#v):\n", currentEvalScope())
 		for i := range localsPanel.locals {
@@ -349,6 +433,8 @@ func loadLocals(p *asyncLoad) {
 		}
 	}
 
+	localsPanel.filterDirty = true
+
 	for _, err := range []error{errarg, errloc} {
 		if err != nil {
 			p.done(err)
@@ -358,6 +444,25 @@ func loadLocals(p *asyncLoad) {
 	p.done(nil)
 }
 
+// updateLocalsFilter determines which local variables match needle, the
+// filter of the Variables panel. Must be called with additionalLoadMu held.
+func updateLocalsFilter(needle string) {
+	localsPanel.filter, localsPanel.filterErr = nil, nil
+	localsPanel.filterDirty = false
+	if needle == "" {
+		return
+	}
+	f, err := newVariableFilter(needle, localsPanel.filterRegexp)
+	if err != nil {
+		localsPanel.filterErr = err
+		return
+	}
+	for _, v := range localsPanel.locals {
+		f.visit(v, v.Name, false, 0)
+	}
+	localsPanel.filter = f
+}
+
 const (
 	varRowHeight    = 20
 	varEditorHeight = 25
@@ -375,14 +480,20 @@ func updateLocals(container *nucular.Window) {
 	defer additionalLoadMu.Unlock()
 
 	w.MenubarBegin()
-	w.Row(varRowHeight).Static(90, 0, 100, 100)
+	w.Row(varRowHeight).Static(90, 0, 80, 100, 100)
 	w.Label("Filter:", "LC")
 	localsPanel.filterEditor.Edit(w)
 	filter := string(localsPanel.filterEditor.Buffer)
+	filterChanged := w.CheckboxText("Regexp", &localsPanel.filterRegexp)
 	w.CheckboxText("Full Types", &localsPanel.fullTypes)
 	w.CheckboxText("Address", &localsPanel.showAddr)
 	w.MenubarEnd()
 
+	if filter != localsPanel.lastFilter {
+		localsPanel.lastFilter = filter
+		filterChanged = true
+	}
+
 	locals := localsPanel.locals
 
 	if len(localsPanel.expressions) > 0 {
@@ -394,8 +505,18 @@ func updateLocals(container *nucular.Window) {
 					if localsPanel.v[i] == nil {
 						w.Row(varRowHeight).Dynamic(1)
 						w.Label(fmt.Sprintf("loading %s", localsPanel.expressions[i].Expr), "LC")
+					} else if localsPanel.expressions[i].condFalse {
+						c := w.Master().Style().Text.Color
+						darken(&c)
+						w.Row(varRowHeight).Dynamic(1)
+						if expr := &localsPanel.expressions[i]; expr.condErr != "" {
+							w.LabelColored(fmt.Sprintf("%s (if %s: %s)", expr.Expr, expr.cond, expr.condErr), "LC", c)
+						} else {
+							w.LabelColored(fmt.Sprintf("%s (if %s)", expr.Expr, expr.cond), "LC", c)
+						}
+						showExprMenu(w, i, localsPanel.v[i], []byte(localsPanel.expressions[i].Expr))
 					} else {
-						showVariable(w, 0, localsPanel.showAddr, localsPanel.fullTypes, i, localsPanel.v[i])
+						showVariable(w, 0, localsPanel.showAddr, localsPanel.fullTypes, nil, i, localsPanel.v[i])
 					}
 				}
 			}
@@ -403,11 +524,45 @@ func updateLocals(container *nucular.Window) {
 		}
 	}
 
+	if filterChanged {
+		localsPanel.filterExpand = true
+		localsPanel.filterLoads = maxFilterLoads
+	}
+	if filterChanged || localsPanel.filterDirty || (localsPanel.filterLoadRunning && !additionalLoadRunning) {
+		// the filter changed, the local variables were loaded again or some of
+		// their children were loaded
+		updateLocalsFilter(filter)
+	}
+	localsPanel.filterLoadRunning = additionalLoadRunning
+
 	if len(locals) > 0 {
 		if w.TreePush(nucular.TreeTab, "Local variables and arguments", true) {
-			for i := range locals {
-				if strings.Index(locals[i].Name, filter) >= 0 {
-					showVariable(w, 0, localsPanel.showAddr, localsPanel.fullTypes, -1, locals[i])
+			f := localsPanel.filter
+			if localsPanel.filterErr != nil {
+				w.Row(varRowHeight).Dynamic(1)
+				w.LabelColored(fmt.Sprintf("Invalid filter: %v", localsPanel.filterErr), "LC", errorColor)
+			} else {
+				if f != nil {
+					f.expand = localsPanel.filterExpand
+					if localsPanel.filterLoads > 0 && f.loadIncomplete() {
+						localsPanel.filterLoads--
+					}
+					if (f.incomplete == nil || localsPanel.filterLoads <= 0) && !additionalLoadRunning {
+						// keep expanding matches until all the data has been loaded
+						localsPanel.filterExpand = false
+					}
+				}
+				for i := range locals {
+					if f == nil || f.visible[locals[i]] {
+						showVariable(w, 0, localsPanel.showAddr, localsPanel.fullTypes, f, -1, locals[i])
+					}
+				}
+				if f != nil && f.incomplete != nil && localsPanel.filterLoads <= 0 && !additionalLoadRunning {
+					w.Row(varRowHeight).Static(200)
+					if w.ButtonText("Load more matches") {
+						localsPanel.filterExpand = true
+						localsPanel.filterLoads = maxFilterLoads
+					}
 				}
 			}
 			w.TreePop()
@@ -430,22 +585,61 @@ func isPinned(expr string) bool {
 	return expr[0] == '['
 }
 
-func loadOneExpr(i int) {
+// exprLoadConfig returns the load configuration used to evaluate expr.
+func exprLoadConfig(expr *Expr) api.LoadConfig {
 	cfg := getVariableLoadConfig()
-	if localsPanel.expressions[i].maxArrayValues > 0 {
-		cfg.MaxArrayValues = localsPanel.expressions[i].maxArrayValues
-		cfg.MaxStringLen = localsPanel.expressions[i].maxStringLen
+	if expr.maxArrayValues > 0 {
+		cfg.MaxArrayValues = expr.maxArrayValues
+		cfg.MaxStringLen = expr.maxStringLen
+	}
+	return cfg
+}
+
+func loadOneExpr(i int) {
+	expr := &localsPanel.expressions[i]
+	cfg := exprLoadConfig(expr)
+	expr.condFalse, expr.condErr = false, ""
+	if expr.cond != "" {
+		ok, err := evalExprCond(expr.cond)
+		expr.condFalse = !ok
+		unreadable := "condition is false"
+		if err != nil {
+			expr.condErr = err.Error()
+			unreadable = expr.condErr
+		}
+		if expr.condFalse {
+			localsPanel.v[i] = wrapApiVariable(&api.Variable{Name: expr.Expr, Unreadable: unreadable}, expr.Expr, expr.Expr, true, 0)
+			return
+		}
 	}
 
 	v := evalScopedExpr(localsPanel.expressions[i].Expr, cfg)
 	v.Name = localsPanel.expressions[i].Expr
 
-	localsPanel.v[i] = wrapApiVariable(v, v.Name, v.Name, true, 0)
+	localsPanel.v[i] = loadVariable(v, v.Name, v.Name)
 	if localsPanel.expressions[i].fmt != nil {
 		localsPanel.expressions[i].fmt(localsPanel.v[i])
 	}
 }
 
+// evalExprCond evaluates cond, which must be either an expression of type
+// bool or a starlark expression returning a boolean.
+func evalExprCond(cond string) (bool, error) {
+	return exprCondValue(evalScopedExpr(cond, api.LoadConfig{}))
+}
+
+// exprCondValue returns the truth value of v, the result of evaluating a
+// condition.
+func exprCondValue(v *api.Variable) (bool, error) {
+	if v.Unreadable != "" {
+		return false, errors.New(v.Unreadable)
+	}
+	if v.Kind == reflect.Bool {
+		return v.Value == "true", nil
+	}
+	return false, errors.New("condition is not a boolean")
+}
+
 func exprsEditor(w *nucular.Window) {
 	w.Row(varEditorHeight).Dynamic(1)
 	active := localsPanel.ed.Edit(w)
@@ -466,7 +660,14 @@ func exprsEditor(w *nucular.Window) {
 }
 
 func addExpression(newexpr string) {
-	localsPanel.expressions = append(localsPanel.expressions, Expr{Expr: newexpr})
+	addConditionalExpression("", newexpr)
+}
+
+// addConditionalExpression adds newexpr to the expressions shown in the
+// Variables panel, if cond is not empty newexpr is only evaluated when
+// cond is true.
+func addConditionalExpression(cond, newexpr string) {
+	localsPanel.expressions = append(localsPanel.expressions, Expr{Expr: newexpr, cond: cond})
 	localsPanel.v = append(localsPanel.v, nil)
 	i := len(localsPanel.v) - 1
 	go func(i int) {
@@ -494,6 +695,28 @@ func showExprMenu(parentw *nucular.Window, exprMenuIdx int, v *Variable, clipb [
 	if w.MenuItem(label.TA("Copy to clipboard", "LC")) {
 		clipboard.Set(string(clipb))
 	}
+	if w.MenuItem(label.TA("Copy as JSON", "LC")) {
+		clipboard.Set(prettyprint.JSON(v.apiVariable()))
+	}
+	if w.MenuItem(label.TA("Copy as Go literal", "LC")) {
+		clipboard.Set(prettyprint.GoLiteral(v.apiVariable()))
+	}
+	if w.MenuItem(label.TA("Copy as YAML", "LC")) {
+		clipboard.Set(prettyprint.YAML(v.apiVariable()))
+	}
+	if w.MenuItem(label.TA("Save to file...", "LC")) {
+		openSaveVariable(w.Master(), v)
+	}
+	// comparisons and snapshots need an expression that can be evaluated
+	// again
+	if v.Expression != "" {
+		if w.MenuItem(label.TA("Compare with...", "LC")) {
+			openCompareWith(w.Master(), v)
+		}
+		if w.MenuItem(label.TA("Save snapshot", "LC")) {
+			saveVariableSnapshot(v.Expression, v.apiVariable())
+		}
+	}
 
 	if exprMenuIdx >= 0 && exprMenuIdx < len(localsPanel.expressions) {
 		pinned := exprIsScoped(localsPanel.expressions[exprMenuIdx].Expr)
@@ -535,6 +758,13 @@ func showExprMenu(parentw *nucular.Window, exprMenuIdx int, v *Variable, clipb [
 		}
 		if exprMenuIdx < len(localsPanel.expressions) {
 			w.CheckboxText("Traced", &localsPanel.expressions[exprMenuIdx].traced)
+			if w.MenuItem(label.TA("Value history...", "LC")) {
+				expr := &localsPanel.expressions[exprMenuIdx]
+				if expr.history == nil {
+					expr.history = &exprHistory{}
+				}
+				openExprHistory(w.Master(), expr.Expr, expr.history)
+			}
 		}
 	} else if v.Expression != "" {
 		if w.MenuItem(label.TA("Add as expression", "LC")) {
@@ -636,11 +866,11 @@ func showExprMenu(parentw *nucular.Window, exprMenuIdx int, v *Variable, clipb [
 
 const maxVariableHeaderWidth = 4096
 
-func variableHeader(w *nucular.Window, addr, fullTypes bool, exprMenu int, v *Variable) bool {
+func variableHeader(w *nucular.Window, addr, fullTypes bool, filter *variableFilter, exprMenu int, v *Variable) bool {
 	style := w.Master().Style()
 
 	w.LayoutSetWidthScaled(maxVariableHeaderWidth)
-	lblrect, out, isopen := w.TreePushCustom(nucular.TreeNode, v.Varname, false)
+	lblrect, out, isopen := w.TreePushCustom(nucular.TreeNode, v.Varname, filter != nil && filter.open[v])
 	if out == nil {
 		return isopen
 	}
@@ -749,7 +979,7 @@ func darken(p *color.RGBA) {
 	p.B = uint8(float64(p.B) * darken)
 }
 
-func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu int, v *Variable) {
+func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, filter *variableFilter, exprMenu int, v *Variable) {
 	style := w.Master().Style()
 
 	if v.Flags&api.VariableShadowed != 0 || v.Unreadable != "" {
@@ -762,8 +992,15 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 		}
 	}
 
+	if filter != nil && !filter.visible[v] {
+		return
+	}
+
 	hdr := func() bool {
-		return variableHeader(w, addr, fullTypes, exprMenu, v)
+		if filter != nil && filter.expand && filter.open[v] {
+			w.TreeOpen(v.Varname)
+		}
+		return variableHeader(w, addr, fullTypes, filter, exprMenu, v)
 	}
 
 	cblbl := func(value string) {
@@ -793,12 +1030,12 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 	switch v.Kind {
 	case reflect.Slice:
 		if hdr() {
-			showArrayOrSliceContents(w, depth, addr, fullTypes, v)
+			showArrayOrSliceContents(w, depth, addr, fullTypes, filter, v)
 			w.TreePop()
 		}
 	case reflect.Array:
 		if hdr() {
-			showArrayOrSliceContents(w, depth, addr, fullTypes, v)
+			showArrayOrSliceContents(w, depth, addr, fullTypes, filter, v)
 			w.TreePop()
 		}
 	case reflect.Ptr:
@@ -812,7 +1049,7 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 					loadMoreStruct(v.Children[0])
 					dynlbl("Loading...")
 				} else {
-					showVariable(w, depth+1, addr, fullTypes, -1, v.Children[0])
+					showVariable(w, depth+1, addr, fullTypes, filter, -1, v.Children[0])
 				}
 				w.TreePop()
 			}
@@ -830,7 +1067,7 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 			cblbl("nil")
 		} else {
 			if hdr() {
-				showStructContents(w, depth, addr, fullTypes, v)
+				showStructContents(w, depth, addr, fullTypes, filter, v)
 				w.TreePop()
 			}
 		}
@@ -840,7 +1077,7 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 				loadMoreStruct(v)
 				dynlbl("Loading...")
 			} else {
-				showStructContents(w, depth, addr, fullTypes, v)
+				showStructContents(w, depth, addr, fullTypes, filter, v)
 			}
 			w.TreePop()
 		}
@@ -849,7 +1086,7 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 			cblbl("nil")
 		} else {
 			if hdr() {
-				showInterfaceContents(w, depth, addr, fullTypes, v)
+				showInterfaceContents(w, depth, addr, fullTypes, filter, v)
 				w.TreePop()
 			}
 		}
@@ -861,7 +1098,7 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 			}
 			for i := range v.Children {
 				if v.Children[i] != nil {
-					showVariable(w, depth+1, addr, fullTypes, -1, v.Children[i])
+					showVariable(w, depth+1, addr, fullTypes, filter, -1, v.Children[i])
 				}
 			}
 			if len(v.Children)/2 != int(v.Len) && v.Addr != 0 {
@@ -891,13 +1128,13 @@ func showVariable(w *nucular.Window, depth int, addr, fullTypes bool, exprMenu i
 	}
 }
 
-func showArrayOrSliceContents(w *nucular.Window, depth int, addr, fullTypes bool, v *Variable) {
+func showArrayOrSliceContents(w *nucular.Window, depth int, addr, fullTypes bool, filter *variableFilter, v *Variable) {
 	if depth < 10 && !v.loading && len(v.Children) > 0 && autoloadMore(v.Children[0]) {
 		v.loading = true
 		loadMoreStruct(v)
 	}
 	for i := range v.Children {
-		showVariable(w, depth+1, addr, fullTypes, -1, v.Children[i])
+		showVariable(w, depth+1, addr, fullTypes, filter, -1, v.Children[i])
 	}
 	if len(v.Children) != int(v.Len) && v.Addr != 0 {
 		w.Row(varRowHeight).Static(moreBtnWidth)
@@ -920,13 +1157,13 @@ func autoloadMore(v *Variable) bool {
 	return false
 }
 
-func showStructContents(w *nucular.Window, depth int, addr, fullTypes bool, v *Variable) {
+func showStructContents(w *nucular.Window, depth int, addr, fullTypes bool, filter *variableFilter, v *Variable) {
 	for i := range v.Children {
-		showVariable(w, depth+1, addr, fullTypes, -1, v.Children[i])
+		showVariable(w, depth+1, addr, fullTypes, filter, -1, v.Children[i])
 	}
 }
 
-func showInterfaceContents(w *nucular.Window, depth int, addr, fullTypes bool, v *Variable) {
+func showInterfaceContents(w *nucular.Window, depth int, addr, fullTypes bool, filter *variableFilter, v *Variable) {
 	if len(v.Children) <= 0 {
 		return
 	}
@@ -949,11 +1186,11 @@ func showInterfaceContents(w *nucular.Window, depth int, addr, fullTypes bool, v
 
 	switch data.Kind {
 	case reflect.Struct:
-		showStructContents(w, depth, addr, fullTypes, data)
+		showStructContents(w, depth, addr, fullTypes, filter, data)
 	case reflect.Array, reflect.Slice:
-		showArrayOrSliceContents(w, depth, addr, fullTypes, data)
+		showArrayOrSliceContents(w, depth, addr, fullTypes, filter, data)
 	default:
-		showVariable(w, depth+1, addr, fullTypes, -1, data)
+		showVariable(w, depth+1, addr, fullTypes, filter, -1, data)
 	}
 }
 
@@ -972,7 +1209,8 @@ func loadMoreMap(v *Variable) {
 				// prevent further attempts at loading
 				v.Len = int64(len(v.Children) / 2)
 			} else {
-				v.Children = append(v.Children, wrapApiVariables(lv.Children, reflect.Map, len(v.Children), v.Expression, true, 0)...)
+				children := loadVariables(lv.Children, reflect.Map, len(v.Children), v.Expression)
+				v.Children = append(v.Children, children...)
 			}
 			wnd.Changed()
 			additionalLoadMu.Lock()
@@ -994,7 +1232,8 @@ func loadMoreArrayOrSlice(v *Variable) {
 				// prevent further attempts at loading
 				v.Len = int64(len(v.Children))
 			} else {
-				v.Children = append(v.Children, wrapApiVariables(lv.Children, v.Kind, len(v.Children), v.Expression, true, 0)...)
+				children := loadVariables(lv.Children, v.Kind, len(v.Children), v.Expression)
+				v.Children = append(v.Children, children...)
 			}
 			additionalLoadMu.Lock()
 			additionalLoadRunning = false
@@ -1015,7 +1254,8 @@ func loadMoreStruct(v *Variable) {
 				dn := v.DisplayName
 				vn := v.Varname
 				lv.Name = v.Name
-				*v = *wrapApiVariable(lv, lv.Name, v.Expression, true, 0)
+				nv := loadVariable(lv, lv.Name, v.Expression)
+				*v = *nv
 				v.Varname = vn
 				v.DisplayName = dn
 			}
@@ -1036,12 +1276,73 @@ func detailsAvailable(v *Variable) openDetailsWindowFn {
 	switch v.Type {
 	case "string", "[]uint8", "[]int32":
 		return newDetailViewer
-	case "[]int", "[]int8", "[]int16", "[]int64", "[]uint", "[]uint16", "[]uint32", "[]uint64":
+	}
+	if numericArrayKind(v) != reflect.Invalid {
 		return newDetailViewer
 	}
+	if (v.Kind == reflect.Slice || v.Kind == reflect.Array) && len(v.Children) > 0 && v.Children[0].Kind == reflect.Struct {
+		return newDetailViewer
+	}
+	if imageType(v.Variable) != "" {
+		return newImageViewer
+	}
 	return nil
 }
 
+var exportFormats = []string{"JSON", "Go literal", "YAML", "Text"}
+
+type saveVariableWindow struct {
+	v      *Variable
+	format int
+	pathEd nucular.TextEditor
+	status string
+}
+
+func openSaveVariable(mw nucular.MasterWindow, v *Variable) {
+	sw := &saveVariableWindow{v: v}
+	sw.pathEd.Flags = nucular.EditSelectable | nucular.EditClipboard
+	sw.pathEd.Buffer = []rune("value.json")
+	mw.PopupOpen(fmt.Sprintf("Save %s", v.DisplayName), dynamicPopupFlags, rect.Rect{100, 100, 400, 700}, true, sw.update)
+}
+
+func (sw *saveVariableWindow) update(w *nucular.Window) {
+	w.Row(20).Static(100, 0)
+	w.Label("Format:", "LC")
+	sw.format = w.ComboSimple(exportFormats, sw.format, 20)
+	w.Row(20).Static(100, 0)
+	w.Label("Path:", "LC")
+	sw.pathEd.Edit(w)
+
+	if sw.status != "" {
+		w.Row(20).Dynamic(1)
+		w.Label(sw.status, "LC")
+	}
+
+	w.Row(20).Static(0, 100, 100)
+	w.Spacing(1)
+	if w.ButtonText("Cancel") {
+		w.Close()
+	}
+	if w.ButtonText("Save") {
+		var out string
+		switch sw.format {
+		case 0:
+			out = prettyprint.JSON(sw.v.apiVariable())
+		case 1:
+			out = prettyprint.GoLiteral(sw.v.apiVariable())
+		case 2:
+			out = prettyprint.YAML(sw.v.apiVariable())
+		default:
+			out = sw.v.MultilineString("")
+		}
+		if err := ioutil.WriteFile(string(sw.pathEd.Buffer), []byte(out+"\n"), 0666); err != nil {
+			sw.status = fmt.Sprintf("Error: %v", err)
+		} else {
+			w.Close()
+		}
+	}
+}
+
 func configureLoadParameters(exprMenuIdx int) func(w *nucular.Window) {
 	expr := &localsPanel.expressions[exprMenuIdx]
 	maxArrayValues := expr.maxArrayValues
@@ -3,6 +3,9 @@
 // fontawesome-webfont.ttf
 // droid-sans.bold.ttf
 // codicon.ttf
+// starlib/goroutines.star
+// starlib/table.star
+// starlib/walk.star
 // DO NOT EDIT!
 
 package assets
@@ -130,6 +133,66 @@ func codiconTtf() (*asset, error) {
 	return a, nil
 }
 
+var _starlibGoroutinesStar = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xa5\x54\xcb\xae\x9b\x30\x10\x5d\xc3\x57\x8c\xd2\x0d\xe8\x22\xc4\xdd\x5e\x35\xab\x4a\xb7\x5d\x54\x59\xf4\xb1\x8a\x22\xe4\xc0\x00\x56\xc1\x8e\x6c\xd3\xdb\xa8\xea\xbf\x77\xc6\x06\x42\x9a\xf4\x21\x75\x81\x64\xcf\x1c\xcf\x9c\x33\x0f\x5e\xc1\x3b\xec\x4f\x68\x2c\x38\x0d\x8d\x54\x35\x08\xfa\x1a\xd9\x3b\x34\xd0\x6a\xa3\x47\x27\x15\xda\x3c\x8e\x6b\x6c\x40\xf4\x7d\x79\x31\x26\xe9\x53\x1c\x6d\x36\x9b\x0f\xe8\x46\xa3\x28\x42\x87\xd0\x4b\xeb\x40\x7b\xe4\xea\x39\x5b\xd8\xeb\x84\x69\xd1\xc1\xc9\xe8\x0a\xad\xcd\xe9\x6d\x1c\x19\xd8\xc2\xfe\x10\x47\x96\x9c\x8e\xce\x45\x1c\xbd\x74\xb2\x47\xf8\x64\x46\xa4\x04\x11\xc5\x20\xf3\x2a\xad\x47\x66\xf0\x58\x14\x45\x4a\x7e\x93\xe3\x37\x87\xaa\x4e\xc8\x9f\xbf\x5d\x60\xec\x9a\x63\xb2\x67\x47\xa8\x96\x6c\xb2\x81\x60\x7e\x4d\xb9\x38\x7e\x74\x34\x28\xbe\x10\x11\x2f\x03\x4c\x90\xda\x8c\xaa\x72\x52\xab\x52\x89\x01\x93\x5e\x57\x77\xc4\xb2\x6b\x96\x36\xe3\xf9\x4e\x68\xe1\xcf\x74\x08\x22\xa5\x37\xe6\xcf\x33\x68\xbb\x85\x9d\x56\x5e\xde\x94\xd6\x97\x22\x1c\xd7\xc8\x7c\x47\x39\xca\x89\x92\xef\xca\xba\x01\x27\x83\xf5\x1d\x5e\xab\xc2\x37\xda\x00\x95\xb3\xea\x80\xb1\x60\x26\x14\xd7\x76\x2a\x7f\xc8\xb9\x6f\x3d\xb4\x05\xa9\x6e\xba\x0c\xc4\x9e\x5f\x27\x6d\x7a\x08\x4c\x2e\xde\x52\xaa\xa4\x51\x7f\xe6\xf0\xd2\x69\x8b\x30\x5a\x9a\xa8\xa5\x32\xd2\x52\x26\x2b\x6b\x04\x71\x29\x5d\x00\xfa\xaa\x56\x5a\x39\x41\x08\x68\xd4\x15\xcf\xdb\x1a\xf4\x62\x38\xd6\x02\xda\x27\x82\x32\xfb\xeb\xc6\xb5\xf9\x67\xca\xfb\x66\x34\x06\x95\x7b\x4f\x5d\x4c\x6f\x14\xf8\x69\xc0\xba\x3c\x9e\xff\x51\x49\x18\x9f\x85\x75\x27\x2c\x89\x58\xb3\x96\xaa\xfd\x7f\xde\x1f\x39\xcb\x3d\xc6\xa5\x1d\x87\x41\x98\xf3\xaf\xeb\x27\x96\xe5\x4b\x2a\x3d\x2a\xda\x90\x39\x64\x0a\x27\x21\x8d\xcd\xc0\xdb\x99\xdd\x4a\xd3\xf1\x7c\x33\xbf\x74\x97\xe6\xba\x61\x19\x0c\x9a\x82\x57\x7a\x18\x34\xab\x31\xd6\x05\x7d\x3e\xa4\xa5\x1d\xfb\xfe\x23\x8e\x7e\x3b\x42\x3c\xe8\xbe\x42\xdb\xbf\xf5\x87\x80\x21\xe4\x9e\xfd\x07\x7a\x10\xae\x39\xfd\x38\x12\x36\x65\x50\xa4\xf0\x00\x8f\x4b\x69\xad\xe6\xf6\x25\xfb\x84\x48\x32\x20\xf5\x93\x1c\xa0\xbe\xb2\x53\x00\xe9\x70\x20\x2a\x87\x8c\xb6\xe0\x2b\xfd\xf1\x70\xcb\x5b\x90\xc6\x3f\x01\xc5\x69\x02\x53\x03\x05\x00\x00")
+
+func starlibGoroutinesStarBytes() ([]byte, error) {
+	return bindataRead(
+		_starlibGoroutinesStar,
+		"starlib/goroutines.star",
+	)
+}
+
+func starlibGoroutinesStar() (*asset, error) {
+	bytes, err := starlibGoroutinesStarBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "starlib/goroutines.star", size: 1283, mode: os.FileMode(420), modTime: time.Unix(1792334517, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _starlibTableStar = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\x8d\x53\xc1\x8e\xd3\x30\x10\x3d\x27\x5f\x31\xca\x5e\x6c\xb6\x1b\xc1\x15\x6d\xf7\xcc\x61\x85\x10\x07\x2e\xa1\x5a\x99\x64\xb2\x35\x72\x9c\xc8\x76\x5b\x3e\x9f\x99\xb1\x53\x4a\x05\x05\xe5\x12\xbf\x99\x79\xf3\x3c\xf3\x7c\x07\x1f\xd0\x2d\x18\x22\xa4\x19\x96\x60\x7d\x82\xa3\x71\x07\x8c\x60\x08\x32\xdf\x1c\xc6\xb6\xae\x07\x1c\x61\x9c\xc3\x64\xd2\x8b\x60\xaa\x9f\xdd\x61\xf2\x71\x03\x61\x3e\x45\xfd\xbe\xae\x9a\xa6\xf9\x8c\xe9\x10\x7c\x14\xa8\x64\x27\x1c\x98\xc7\x64\x26\x38\xd9\xb4\x07\xe3\xec\xab\x27\xfc\x4c\x91\xf6\x08\xa3\x0d\x31\x81\xb3\x1e\x09\xf7\xc9\x58\xe2\x61\x3c\x27\x81\x37\x13\xe9\xa0\x1e\x75\x25\xec\x5b\xe8\xba\x98\x82\xea\xd1\x39\xcd\xbd\x80\xff\xc0\x7a\x6e\xbe\x13\x80\x7e\xca\x39\xee\xea\xea\x64\x87\xb4\x97\x3a\x87\x5e\xf5\xa5\x86\x13\x8a\x0c\xca\xb9\xaa\xa2\x4b\x09\x64\x05\x30\xfe\x15\xd5\x64\xbd\xe2\x7a\x0a\xeb\x0d\xf0\x5f\xe6\xd5\x9a\x47\x50\x55\x76\x84\x12\xee\xec\x4e\xc3\x13\xe4\x30\x1d\x24\x5c\x9d\x8f\x24\xe4\x22\xb1\xae\xf8\xe2\xa2\xee\x77\x19\xdd\x2a\x0e\xee\xcf\x92\xf8\xa2\x6b\xea\xb5\xbe\x55\xdb\x59\x8d\x85\xc7\x4b\x99\x59\x85\x30\xb4\x66\x59\xd0\x0f\x45\x02\xf1\x37\xf4\xbd\x01\xf5\x4b\xe2\xc3\xa5\x44\xad\xb9\x14\x5d\xc4\xbf\x72\x70\x86\xdc\x63\x85\x1b\x80\xa6\xfd\x3e\xd3\xcc\x24\x5b\xb7\xb4\xe2\x60\x17\xc5\x5c\x41\xbc\x02\xcd\x57\x5f\x52\xa4\x52\x67\xa7\x89\x0d\x6f\x18\xed\x13\xc7\x6f\xf8\x2c\x1b\x45\x58\xd4\x0d\xd7\x96\x6e\x24\xea\xd0\xa7\x17\x86\x14\xfe\x58\xc2\x86\xcc\x88\x6e\xa0\x2c\x67\x27\x9b\xb6\x0f\xef\xae\xfc\x6d\x64\x3d\x3c\x7a\x34\xfd\x1e\xd0\xe1\x84\xf4\x6c\xe6\x51\x1c\x1b\x9d\xed\x91\x0f\x99\x37\x42\xe6\x14\xeb\x73\xbc\xbc\x2e\x4a\xc8\x6d\xb2\xd8\x23\x6d\x14\x29\xa4\x3e\xce\x1e\x37\x52\xa3\xdb\x2f\x26\x58\xd6\x5d\x57\x9e\xc2\xc7\xf6\x19\x7d\x2d\x1e\x63\x5d\xf0\xb4\x85\xb7\x60\xfc\x50\x8e\x8f\xe0\x79\x35\x9c\x29\xc0\x79\xc6\x5d\x77\x24\x26\x6a\x4a\x3b\xea\xc6\xfc\x38\x46\xf6\x4c\xee\x9f\x81\x0b\x13\x79\xbd\xbb\x5c\x43\x19\x4f\x1e\xe0\xcd\xf9\x94\xb5\xe4\xe7\xcc\x19\x7c\xc9\xff\x1e\xd1\x9f\xf7\x57\xfa\x76\xcd\x5d\xc3\x26\x5d\x3b\x77\xd9\xb3\xeb\x1e\xec\x66\x7d\x31\xe8\x0f\x13\x06\x93\x50\xfd\x63\xad\x5a\x93\x61\x7f\x02\x60\x44\x94\xf4\xfd\x04\x00\x00")
+
+func starlibTableStarBytes() ([]byte, error) {
+	return bindataRead(
+		_starlibTableStar,
+		"starlib/table.star",
+	)
+}
+
+func starlibTableStar() (*asset, error) {
+	bytes, err := starlibTableStarBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "starlib/table.star", size: 1277, mode: os.FileMode(420), modTime: time.Unix(1792334517, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
+var _starlibWalkStar = []byte("\x1f\x8b\x08\x00\x00\x00\x00\x00\x02\xff\xa5\x93\x41\x8f\x9b\x30\x10\x85\xcf\xf0\x2b\x46\xe9\x05\x24\x16\xb1\xd7\x55\xe9\xa5\x52\xb5\x87\xaa\x87\xaa\xea\x05\x45\x91\x37\x0c\xc1\xc2\xd8\xc8\x76\x48\xf2\xef\x3b\xb6\x21\x90\xdd\x68\x77\xa5\x9e\x22\x67\x98\xf1\xfb\xde\xf8\x7d\x81\x67\x14\x03\x6a\x03\x56\xc1\x89\x89\x0e\x8c\xe0\x7b\x34\x19\xf4\x6c\x30\xc0\x64\x0d\x82\xcb\x0e\xdd\x8f\xb1\x06\x54\x03\xb6\x45\xb0\x4c\x1f\xd0\xc2\xa0\x15\x7d\x6b\xf2\x38\xae\xb1\xf1\xed\x3b\xdf\x9e\xe0\x79\xd0\x19\x34\x32\xa3\xb6\x9e\xdb\xf2\xe1\x31\x7d\x8a\xa3\xcd\x66\xf3\x9d\x09\x61\xa8\x90\xf0\x0c\x50\x60\x9f\x42\xa3\x34\x20\xdb\xb7\xfe\x88\xd2\xce\x57\xf8\x41\x40\x45\xa6\x35\xbb\x40\x98\x68\xac\x22\x55\xa7\x16\x25\xcd\x00\x8d\xf6\xa8\xa5\x81\x1f\x4c\x18\xcc\x69\x7a\x1c\x8d\x50\x02\x8e\x4c\x24\xbf\x94\xc4\xcc\x77\xa5\xf9\x5f\xa6\x39\x7b\x11\x18\x47\x92\xca\x63\xfe\x13\x65\x1c\xf1\x26\x68\x83\x6f\x25\x14\x13\xa8\x3b\x7e\x05\x49\x52\xfd\x97\xfe\x8f\x38\x72\x0a\x39\x70\xba\x8f\xc9\x03\x26\xd2\xa1\xb8\xf6\x40\x31\xd2\x78\x71\xc4\x8a\x6f\x53\x28\xcb\xa0\xc5\x7d\x10\x05\x75\x2b\x6b\xc8\xd1\xab\x31\xaf\xed\xe8\xf0\x42\xa3\xdc\xa0\xb5\x23\xd2\xea\xcb\xec\x07\x75\x7f\xd6\x84\xfe\x3d\x13\x82\xdc\x40\xd5\x39\xaa\x7e\xc1\xe9\x68\xed\x55\xf7\x21\x88\x7b\x0a\x13\x89\xc4\xb3\xdd\x35\x1c\x45\x1d\xd6\xdd\xb3\xf3\xae\xc6\xc1\xb6\xe5\x63\x51\x14\x77\x96\x2e\x55\xbd\x46\x74\xc7\x99\x70\xf5\xd0\x88\x91\x69\xcb\xe5\x01\x98\xf5\xb5\x41\x71\x69\x51\x4f\x0e\x34\x4a\x08\x75\x72\x65\x57\xf3\xd7\xdf\x28\xf9\xbf\x67\xb2\x76\x68\xb5\xf7\x2b\xda\xbc\xff\xd1\xd9\xe4\x06\xb8\x57\x3a\x56\xc5\x76\x3e\xaf\x5d\x5b\xbf\x94\xfb\xbe\x46\x5e\xcd\x58\x2d\x00\xdb\xe0\xf5\x9e\x28\x71\x6f\x6f\x22\x35\x68\xac\xdf\x84\xea\xf7\x04\xe8\xcc\xe0\xb2\xc6\x33\x5e\x83\x3a\x85\xca\xbc\x93\x2a\xbf\x8c\x53\xcb\x69\x1b\x6e\xfa\xd5\xae\x3f\xfa\x38\xb9\xa5\x49\x5f\xb5\x8d\xa3\x37\x01\x17\xac\x7f\xa9\x19\x4c\x59\x7e\x02\x9d\xb3\x61\x40\x59\x27\x3c\x05\xc2\x76\xe3\x92\x90\x72\x24\x68\x08\x66\x7b\xf1\x69\x3c\xe1\x83\xbe\x85\x5d\x42\xe2\xba\xef\x00\x52\x52\x16\x3a\x0a\x08\x5f\x60\xe7\x88\xbc\x22\xba\x09\xd7\x07\x74\xcb\xf5\x13\x1b\x45\x62\x5c\x81\x75\x0b\x58\xe7\x37\x7a\x05\x5b\x13\xfd\x03\x97\xa0\x35\x0e\x56\x05\x00\x00")
+
+func starlibWalkStarBytes() ([]byte, error) {
+	return bindataRead(
+		_starlibWalkStar,
+		"starlib/walk.star",
+	)
+}
+
+func starlibWalkStar() (*asset, error) {
+	bytes, err := starlibWalkStarBytes()
+	if err != nil {
+		return nil, err
+	}
+
+	info := bindataFileInfo{name: "starlib/walk.star", size: 1366, mode: os.FileMode(420), modTime: time.Unix(1792334517, 0)}
+	a := &asset{bytes: bytes, info: info}
+	return a, nil
+}
+
 // Asset loads and returns the asset for the given name.
 // It returns an error if the asset could not be found or
 // could not be loaded.
@@ -185,6 +248,9 @@ var _bindata = map[string]func() (*asset, error){
 	"fontawesome-webfont.ttf": fontawesomeWebfontTtf,
 	"droid-sans.bold.ttf":     droidSansBoldTtf,
 	"codicon.ttf":             codiconTtf,
+	"starlib/goroutines.star": starlibGoroutinesStar,
+	"starlib/table.star":      starlibTableStar,
+	"starlib/walk.star":       starlibWalkStar,
 }
 
 // AssetDir returns the file names below a certain
@@ -231,6 +297,11 @@ var _bintree = &bintree{nil, map[string]*bintree{
 	"codicon.ttf":             &bintree{codiconTtf, map[string]*bintree{}},
 	"droid-sans.bold.ttf":     &bintree{droidSansBoldTtf, map[string]*bintree{}},
 	"fontawesome-webfont.ttf": &bintree{fontawesomeWebfontTtf, map[string]*bintree{}},
+	"starlib": &bintree{nil, map[string]*bintree{
+		"goroutines.star": &bintree{starlibGoroutinesStar, map[string]*bintree{}},
+		"table.star":      &bintree{starlibTableStar, map[string]*bintree{}},
+		"walk.star":       &bintree{starlibWalkStar, map[string]*bintree{}},
+	}},
 }}
 
 // RestoreAsset restores an asset under the given directory
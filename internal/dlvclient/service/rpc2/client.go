@@ -7,6 +7,7 @@ import (
 	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
+	"strconv"
 	"sync"
 	"time"
 
@@ -262,6 +263,55 @@ func (c *RPCClient) EvalVariable(scope api.EvalScope, expr string, cfg api.LoadC
 	return out.Variable, err
 }
 
+// layoutAddr is the address at which TypeSize places the variables it
+// uses to compute the size of a type, the memory at this address is never
+// read.
+const layoutAddr = 0x1000
+
+// TypeSize returns the size of the type called name, computed as the
+// distance between two consecutive elements of an array of that type.
+func (c *RPCClient) TypeSize(scope api.EvalScope, name string) (int64, error) {
+	v, err := c.EvalVariable(scope, fmt.Sprintf("*(*%q)(%#x)", "[2]"+name, layoutAddr), api.LoadConfig{false, 0, 0, 2, 0})
+	if err != nil {
+		return 0, err
+	}
+	if len(v.Children) != 2 {
+		return 0, fmt.Errorf("could not determine the size of %s", name)
+	}
+	return int64(v.Children[1].Addr - v.Children[0].Addr), nil
+}
+
+// ReadMemory reads size bytes of memory starting at addr, chunkSize bytes
+// at a time. After each chunk is read fn is called with its offset from
+// addr and its contents, reading stops if fn returns an error.
+func (c *RPCClient) ReadMemory(scope api.EvalScope, addr uintptr, size, chunkSize int, fn func(off int, buf []byte) error) error {
+	for off := 0; off < size; off += chunkSize {
+		n := chunkSize
+		if off+n > size {
+			n = size - off
+		}
+		v, err := c.EvalVariable(scope, fmt.Sprintf("*(*[%d]uint8)(%#x)", n, addr+uintptr(off)), api.LoadConfig{false, 0, 0, n, -1})
+		if err != nil {
+			return err
+		}
+		if v.Unreadable != "" {
+			return fmt.Errorf("could not read memory at %#x: %s", addr+uintptr(off), v.Unreadable)
+		}
+		buf := make([]byte, len(v.Children))
+		for i := range v.Children {
+			if v.Children[i].Unreadable != "" {
+				return fmt.Errorf("could not read memory at %#x: %s", v.Children[i].Addr, v.Children[i].Unreadable)
+			}
+			b, _ := strconv.Atoi(v.Children[i].Value)
+			buf[i] = byte(b)
+		}
+		if err := fn(off, buf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *RPCClient) SetVariable(scope api.EvalScope, symbol, value string) error {
 	out := new(SetOut)
 	return c.call("Set", SetIn{scope, symbol, value}, out)
@@ -0,0 +1,491 @@
+package prettyprint
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+)
+
+// JSON returns a JSON representation of v.
+// Structs and maps with string or numeric keys are converted to JSON
+// objects, pointers and interfaces are replaced by the value they point to.
+// Values that were not loaded are represented by a string describing
+// them, for example "...+10 more" at the end of a truncated array.
+func JSON(v *api.Variable) string {
+	var buf bytes.Buffer
+	writeJSON(&buf, exportTree(v), "")
+	return buf.String()
+}
+
+// YAML returns a YAML representation of v, see JSON.
+func YAML(v *api.Variable) string {
+	var buf bytes.Buffer
+	writeYAML(&buf, exportTree(v), "", true)
+	return buf.String()
+}
+
+// exported value types, the tree built by exportTree contains: nil,
+// exportString, exportNumber, bool, []interface{} and *exportObject.
+type exportString string
+type exportNumber string
+
+type exportObject struct {
+	keys   []string
+	values []interface{}
+}
+
+func (o *exportObject) add(k string, v interface{}) {
+	o.keys = append(o.keys, k)
+	o.values = append(o.values, v)
+}
+
+func moreMarker(n int) exportString {
+	return exportString(fmt.Sprintf("...+%d more", n))
+}
+
+func exportTree(v *api.Variable) interface{} {
+	if v.Unreadable != "" {
+		return exportString(fmt.Sprintf("(unreadable %s)", v.Unreadable))
+	}
+
+	switch v.Kind {
+	case reflect.Slice, reflect.Array:
+		if v.Kind == reflect.Slice && v.Base == 0 && len(v.Children) == 0 {
+			return nil
+		}
+		r := make([]interface{}, 0, len(v.Children)+1)
+		for i := range v.Children {
+			r = append(r, exportTree(&v.Children[i]))
+		}
+		if len(v.Children) != int(v.Len) {
+			r = append(r, moreMarker(int(v.Len)-len(v.Children)))
+		}
+		return r
+
+	case reflect.Ptr:
+		if v.Type == "" || len(v.Children) < 1 || v.Children[0].Addr == 0 {
+			return nil
+		}
+		if v.Children[0].OnlyAddr {
+			return exportString(fmt.Sprintf("(%s)(%#x)", v.Type, v.Children[0].Addr))
+		}
+		return exportTree(&v.Children[0])
+
+	case reflect.UnsafePointer:
+		return exportString(fmt.Sprintf("unsafe.Pointer(%#x)", v.Children[0].Addr))
+
+	case reflect.String:
+		s := v.Value
+		if len(s) != int(v.Len) {
+			s = fmt.Sprintf("%s...+%d more", s, int(v.Len)-len(s))
+		}
+		return exportString(s)
+
+	case reflect.Chan:
+		if len(v.Children) == 0 {
+			return nil
+		}
+		return exportString(fmt.Sprintf("%s %s/%s", v.Type, v.Children[0].Value, v.Children[1].Value))
+
+	case reflect.Struct:
+		if int(v.Len) != len(v.Children) && len(v.Children) == 0 {
+			return exportString(fmt.Sprintf("(*%s)(%#x)", v.Type, v.Addr))
+		}
+		r := &exportObject{}
+		for i := range v.Children {
+			r.add(v.Children[i].Name, exportTree(&v.Children[i]))
+		}
+		return r
+
+	case reflect.Interface:
+		if v.Addr == 0 || len(v.Children) == 0 || v.Children[0].Kind == reflect.Invalid {
+			return nil
+		}
+		return exportTree(&v.Children[0])
+
+	case reflect.Map:
+		if v.Base == 0 && len(v.Children) == 0 {
+			return nil
+		}
+		objkeys := true
+		for i := 0; i < len(v.Children); i += 2 {
+			if !isExportKey(&v.Children[i]) {
+				objkeys = false
+				break
+			}
+		}
+		if objkeys {
+			r := &exportObject{}
+			for i := 0; i+1 < len(v.Children); i += 2 {
+				r.add(v.Children[i].Value, exportTree(&v.Children[i+1]))
+			}
+			if len(v.Children)/2 != int(v.Len) {
+				r.add("...", moreMarker(int(v.Len)-len(v.Children)/2))
+			}
+			return r
+		}
+		r := make([]interface{}, 0, len(v.Children)/2+1)
+		for i := 0; i+1 < len(v.Children); i += 2 {
+			kv := &exportObject{}
+			kv.add("key", exportTree(&v.Children[i]))
+			kv.add("value", exportTree(&v.Children[i+1]))
+			r = append(r, kv)
+		}
+		if len(v.Children)/2 != int(v.Len) {
+			r = append(r, moreMarker(int(v.Len)-len(v.Children)/2))
+		}
+		return r
+
+	case reflect.Func:
+		if v.Value == "" {
+			return nil
+		}
+		return exportString(v.Value)
+
+	case reflect.Bool:
+		return v.Value == "true"
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return exportNumber(v.Value)
+
+	case reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(v.Value, 64); err != nil || strings.ContainsAny(v.Value, "IN") {
+			// +Inf, -Inf and NaN can not be represented as JSON numbers
+			return exportString(v.Value)
+		}
+		return exportNumber(v.Value)
+
+	case reflect.Complex64, reflect.Complex128:
+		return exportString(fmt.Sprintf("(%s + %si)", v.Children[0].Value, v.Children[1].Value))
+
+	default:
+		if v.Value != "" {
+			return exportString(v.Value)
+		}
+		return exportString(fmt.Sprintf("(unknown %s)", v.Kind))
+	}
+}
+
+// isExportKey returns true if the map key k can be used as the key of an
+// object.
+func isExportKey(k *api.Variable) bool {
+	switch k.Kind {
+	case reflect.String:
+		return len(k.Value) == int(k.Len)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return true
+	}
+	return false
+}
+
+func writeJSON(buf io.Writer, v interface{}, indent string) {
+	switch v := v.(type) {
+	case nil:
+		fmt.Fprint(buf, "null")
+	case bool:
+		fmt.Fprintf(buf, "%v", v)
+	case exportNumber:
+		fmt.Fprint(buf, string(v))
+	case exportString:
+		writeJSONString(buf, string(v))
+	case []interface{}:
+		if len(v) == 0 {
+			fmt.Fprint(buf, "[]")
+			return
+		}
+		fmt.Fprint(buf, "[")
+		for i := range v {
+			fmt.Fprintf(buf, "\n%s%s", indent, indentString)
+			writeJSON(buf, v[i], indent+indentString)
+			if i != len(v)-1 {
+				fmt.Fprint(buf, ",")
+			}
+		}
+		fmt.Fprintf(buf, "\n%s]", indent)
+	case *exportObject:
+		if len(v.keys) == 0 {
+			fmt.Fprint(buf, "{}")
+			return
+		}
+		fmt.Fprint(buf, "{")
+		for i := range v.keys {
+			fmt.Fprintf(buf, "\n%s%s", indent, indentString)
+			writeJSONString(buf, v.keys[i])
+			fmt.Fprint(buf, ": ")
+			writeJSON(buf, v.values[i], indent+indentString)
+			if i != len(v.keys)-1 {
+				fmt.Fprint(buf, ",")
+			}
+		}
+		fmt.Fprintf(buf, "\n%s}", indent)
+	}
+}
+
+// writeJSONString writes s as a JSON string, the escapes used by
+// strconv.Quote are not all valid JSON.
+func writeJSONString(buf io.Writer, s string) {
+	var out bytes.Buffer
+	out.WriteByte('"')
+	for _, ch := range s {
+		switch {
+		case ch == '"' || ch == '\\':
+			out.WriteByte('\\')
+			out.WriteRune(ch)
+		case ch == '\n':
+			out.WriteString(`\n`)
+		case ch == '\r':
+			out.WriteString(`\r`)
+		case ch == '\t':
+			out.WriteString(`\t`)
+		case ch < 0x20 || ch == 0x7f || ch == '\u2028' || ch == '\u2029':
+			fmt.Fprintf(&out, `\u%04x`, ch)
+		default:
+			out.WriteRune(ch)
+		}
+	}
+	out.WriteByte('"')
+	buf.Write(out.Bytes())
+}
+
+func writeYAML(buf io.Writer, v interface{}, indent string, inline bool) {
+	const yamlIndent = "  "
+	switch v := v.(type) {
+	case nil:
+		fmt.Fprint(buf, "null")
+	case bool:
+		fmt.Fprintf(buf, "%v", v)
+	case exportNumber:
+		fmt.Fprint(buf, string(v))
+	case exportString:
+		// JSON strings are valid YAML double-quoted scalars
+		writeJSONString(buf, string(v))
+	case []interface{}:
+		if len(v) == 0 {
+			fmt.Fprint(buf, "[]")
+			return
+		}
+		for i := range v {
+			if i != 0 || !inline {
+				fmt.Fprintf(buf, "\n%s", indent)
+			}
+			fmt.Fprint(buf, "- ")
+			writeYAML(buf, v[i], indent+yamlIndent, true)
+		}
+	case *exportObject:
+		if len(v.keys) == 0 {
+			fmt.Fprint(buf, "{}")
+			return
+		}
+		for i := range v.keys {
+			if i != 0 || !inline {
+				fmt.Fprintf(buf, "\n%s", indent)
+			}
+			writeJSONString(buf, v.keys[i])
+			fmt.Fprint(buf, ":")
+			switch child := v.values[i].(type) {
+			case []interface{}:
+				if len(child) > 0 {
+					writeYAML(buf, child, indent, false)
+					continue
+				}
+			case *exportObject:
+				if len(child.keys) > 0 {
+					writeYAML(buf, child, indent+yamlIndent, false)
+					continue
+				}
+			}
+			fmt.Fprint(buf, " ")
+			writeYAML(buf, v.values[i], indent+yamlIndent, true)
+		}
+	}
+}
+
+// GoLiteral returns a Go expression that evaluates to the value of v, for
+// example main.T{A: 1, B: []int{1, 2}}.
+// Values that could not be loaded are replaced by their zero value and a
+// comment.
+func GoLiteral(v *api.Variable) string {
+	var buf bytes.Buffer
+	writeGoLiteral(&buf, v, "")
+	return buf.String()
+}
+
+var goBasicTypes = map[string]bool{
+	"bool": true, "string": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true, "uintptr": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+	"byte": true, "rune": true,
+}
+
+func goLiteralType(v *api.Variable) string {
+	return ShortenType(v.Type)
+}
+
+func writeGoLiteral(buf io.Writer, v *api.Variable, indent string) {
+	if v.Unreadable != "" {
+		fmt.Fprintf(buf, "%s /* unreadable %s */", goZeroValue(v), strings.Replace(v.Unreadable, "*/", "* /", -1))
+		return
+	}
+
+	// basic values of named types need a conversion
+	conv := func(val string) {
+		if goBasicTypes[v.Type] {
+			fmt.Fprint(buf, val)
+		} else {
+			fmt.Fprintf(buf, "%s(%s)", goLiteralType(v), val)
+		}
+	}
+
+	switch v.Kind {
+	case reflect.Slice:
+		if v.Base == 0 && len(v.Children) == 0 {
+			fmt.Fprint(buf, "nil")
+			return
+		}
+		writeGoLiteralElements(buf, v, indent)
+	case reflect.Array:
+		writeGoLiteralElements(buf, v, indent)
+	case reflect.Ptr:
+		if v.Type == "" || len(v.Children) < 1 || v.Children[0].Addr == 0 {
+			fmt.Fprint(buf, "nil")
+			return
+		}
+		elem := &v.Children[0]
+		if elem.OnlyAddr {
+			fmt.Fprintf(buf, "nil /* (%s)(%#x) not loaded */", v.Type, elem.Addr)
+			return
+		}
+		switch elem.Kind {
+		case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+			fmt.Fprint(buf, "&")
+			writeGoLiteral(buf, elem, indent)
+		default:
+			fmt.Fprintf(buf, "func() %s { v := ", goLiteralType(v))
+			writeGoLiteral(buf, elem, indent)
+			fmt.Fprint(buf, "; return &v }()")
+		}
+	case reflect.UnsafePointer:
+		fmt.Fprintf(buf, "unsafe.Pointer(uintptr(%#x))", v.Children[0].Addr)
+	case reflect.String:
+		s := strconv.Quote(v.Value)
+		if len(v.Value) != int(v.Len) {
+			s += fmt.Sprintf(" /* ...+%d more */", int(v.Len)-len(v.Value))
+		}
+		conv(s)
+	case reflect.Chan, reflect.Func:
+		if v.Value == "" && (len(v.Children) == 0 || v.Addr == 0) {
+			fmt.Fprint(buf, "nil")
+		} else {
+			fmt.Fprintf(buf, "nil /* %s */", strings.Replace(Singleline(v, true, false), "*/", "* /", -1))
+		}
+	case reflect.Struct:
+		if int(v.Len) != len(v.Children) && len(v.Children) == 0 {
+			fmt.Fprintf(buf, "%s{} /* (*%s)(%#x) not loaded */", goLiteralType(v), v.Type, v.Addr)
+			return
+		}
+		fmt.Fprintf(buf, "%s{", goLiteralType(v))
+		for i := range v.Children {
+			fmt.Fprintf(buf, "\n%s%s%s: ", indent, indentString, v.Children[i].Name)
+			writeGoLiteral(buf, &v.Children[i], indent+indentString)
+			fmt.Fprint(buf, ",")
+		}
+		if len(v.Children) > 0 {
+			fmt.Fprintf(buf, "\n%s", indent)
+		}
+		fmt.Fprint(buf, "}")
+	case reflect.Interface:
+		if v.Addr == 0 || len(v.Children) == 0 || v.Children[0].Kind == reflect.Invalid {
+			fmt.Fprint(buf, "nil")
+			return
+		}
+		writeGoLiteral(buf, &v.Children[0], indent)
+	case reflect.Map:
+		if v.Base == 0 && len(v.Children) == 0 {
+			fmt.Fprint(buf, "nil")
+			return
+		}
+		fmt.Fprintf(buf, "%s{", goLiteralType(v))
+		for i := 0; i+1 < len(v.Children); i += 2 {
+			fmt.Fprintf(buf, "\n%s%s", indent, indentString)
+			writeGoLiteral(buf, &v.Children[i], indent+indentString)
+			fmt.Fprint(buf, ": ")
+			writeGoLiteral(buf, &v.Children[i+1], indent+indentString)
+			fmt.Fprint(buf, ",")
+		}
+		if len(v.Children)/2 != int(v.Len) {
+			fmt.Fprintf(buf, "\n%s%s// ...+%d more", indent, indentString, int(v.Len)-len(v.Children)/2)
+		}
+		if len(v.Children) > 0 {
+			fmt.Fprintf(buf, "\n%s", indent)
+		}
+		fmt.Fprint(buf, "}")
+	case reflect.Float32, reflect.Float64:
+		switch v.Value {
+		case "+Inf":
+			conv("math.Inf(1)")
+		case "-Inf":
+			conv("math.Inf(-1)")
+		case "NaN":
+			conv("math.NaN()")
+		default:
+			conv(v.Value)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		conv(fmt.Sprintf("complex(%s, %s)", v.Children[0].Value, v.Children[1].Value))
+	default:
+		if v.Value == "" {
+			fmt.Fprintf(buf, "%s /* unknown %s */", goZeroValue(v), v.Kind)
+			return
+		}
+		conv(v.Value)
+	}
+}
+
+func writeGoLiteralElements(buf io.Writer, v *api.Variable, indent string) {
+	fmt.Fprintf(buf, "%s{", goLiteralType(v))
+	nl := shouldNewlineArray(v, true)
+	for i := range v.Children {
+		if nl {
+			fmt.Fprintf(buf, "\n%s%s", indent, indentString)
+		} else if i != 0 {
+			fmt.Fprint(buf, " ")
+		}
+		writeGoLiteral(buf, &v.Children[i], indent+indentString)
+		if nl || i != len(v.Children)-1 {
+			fmt.Fprint(buf, ",")
+		}
+	}
+	if len(v.Children) != int(v.Len) {
+		if nl {
+			fmt.Fprintf(buf, "\n%s%s", indent, indentString)
+		} else if len(v.Children) > 0 {
+			fmt.Fprint(buf, " ")
+		}
+		fmt.Fprintf(buf, "/* ...+%d more */", int(v.Len)-len(v.Children))
+	}
+	if nl {
+		fmt.Fprintf(buf, "\n%s", indent)
+	}
+	fmt.Fprint(buf, "}")
+}
+
+func goZeroValue(v *api.Variable) string {
+	switch v.Kind {
+	case reflect.Bool:
+		return "false"
+	case reflect.String:
+		return `""`
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr, reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return "0"
+	case reflect.Struct, reflect.Array:
+		return goLiteralType(v) + "{}"
+	default:
+		return "nil"
+	}
+}
@@ -0,0 +1,95 @@
+package starbind
+
+import (
+	"sort"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+// Complete returns the possible completions of word, which is either an
+// identifier or a sequence of identifiers separated by dots. Identifiers
+// are completed using builtins and globals, attributes are completed using
+// the AttrNames method of the value they belong to.
+func (env *Env) Complete(word string) []string {
+	path := strings.Split(word, ".")
+	last := path[len(path)-1]
+	path = path[:len(path)-1]
+
+	var names []string
+	if len(path) == 0 {
+		names = env.globalNames()
+	} else {
+		v := env.lookupGlobal(path[0])
+		for _, attr := range path[1:] {
+			if v == nil {
+				break
+			}
+			v = attrValue(v, attr)
+		}
+		if v, ok := v.(starlark.HasAttrs); ok {
+			names = v.AttrNames()
+		}
+	}
+
+	prefix := ""
+	if len(path) > 0 {
+		prefix = strings.Join(path, ".") + "."
+	}
+	var r []string
+	for _, name := range names {
+		if strings.HasPrefix(name, last) {
+			r = append(r, prefix+name)
+		}
+	}
+	sort.Strings(r)
+	return r
+}
+
+func (env *Env) globalNames() []string {
+	var r []string
+	for name := range starlark.Universe {
+		r = append(r, name)
+	}
+	for name := range env.env {
+		r = append(r, name)
+	}
+	env.replMu.Lock()
+	for name := range env.replGlobals {
+		r = append(r, name)
+	}
+	env.replMu.Unlock()
+	return r
+}
+
+func (env *Env) lookupGlobal(name string) starlark.Value {
+	env.replMu.Lock()
+	v := env.replGlobals[name]
+	env.replMu.Unlock()
+	if v != nil {
+		return v
+	}
+	if v := env.env[name]; v != nil {
+		return v
+	}
+	return starlark.Universe[name]
+}
+
+// attrValue returns the attribute name of v, or nil if it does not exist
+// or can not be read.
+func attrValue(v starlark.Value, name string) (r starlark.Value) {
+	defer func() {
+		if ierr := recover(); ierr != nil {
+			r = nil
+		}
+	}()
+	hasattrs, ok := v.(starlark.HasAttrs)
+	if !ok {
+		return nil
+	}
+	r, err := hasattrs.Attr(name)
+	if err != nil {
+		return nil
+	}
+	return r
+}
@@ -0,0 +1,70 @@
+package starbind
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"go.starlark.net/starlark"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+)
+
+func (env *Env) registerFormatterBuiltin(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) != 2 {
+		return nil, decorateError(thread, fmt.Errorf("wrong number of arguments"))
+	}
+	pattern, ok := args[0].(starlark.String)
+	if !ok {
+		return nil, decorateError(thread, fmt.Errorf("first argument of register_formatter was not a string"))
+	}
+	fn, ok := args[1].(starlark.Callable)
+	if !ok {
+		return nil, decorateError(thread, fmt.Errorf("second argument of register_formatter was not a function"))
+	}
+	err := env.ctx.RegisterFormatter(string(pattern), func(v *api.Variable) (string, error) {
+		return env.callFormatter(fn, v)
+	})
+	return starlark.None, decorateError(thread, err)
+}
+
+// callFormatter calls fn passing v to it, errors and panics are returned
+// as errors so that a broken formatter does not affect anything else.
+func (env *Env) callFormatter(fn starlark.Callable, v *api.Variable) (s string, err error) {
+	defer func() {
+		if ierr := recover(); ierr != nil {
+			err = fmt.Errorf("panic: %v", ierr)
+		}
+	}()
+	// converting pointers to starlark values loads their targets in place
+	x, err := env.variableValueToStarlarkValue(copyVariable(v), true)
+	if err != nil {
+		return "", err
+	}
+	if x == nil {
+		x = starlark.None
+	}
+	// formatters are called while variables are loaded, possibly while
+	// other scripts are running, their output is discarded
+	thread, release := env.newBackgroundThread(ioutil.Discard)
+	defer release()
+	r, err := starlark.Call(thread, fn, starlark.Tuple{x}, nil)
+	if err != nil {
+		return "", err
+	}
+	if r, ok := r.(starlark.String); ok {
+		return string(r), nil
+	}
+	return r.String(), nil
+}
+
+// copyVariable returns a deep copy of v.
+func copyVariable(v *api.Variable) *api.Variable {
+	r := *v
+	if v.Children != nil {
+		r.Children = make([]api.Variable, len(v.Children))
+		for i := range v.Children {
+			r.Children[i] = *copyVariable(&v.Children[i])
+		}
+	}
+	return &r
+}
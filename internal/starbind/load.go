@@ -0,0 +1,94 @@
+package starbind
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"go.starlark.net/starlark"
+
+	"github.com/aarzilli/gdlv/internal/assets"
+)
+
+// libraryDir is the directory of the assets containing the bundled library
+// of starlark modules.
+const libraryDir = "starlib"
+
+// loadCacheName is the name of the thread local containing the modules
+// loaded by the thread.
+const loadCacheName = "dlv_load_cache"
+
+type loadEntry struct {
+	globals starlark.StringDict
+	err     error
+}
+
+// load implements the load statement. Modules are searched, in order, in
+// the directory of the file executing the load statement, in the
+// directories returned by Context.ScriptPath and in the bundled library.
+// Each module is executed only once for each thread, on the thread that
+// loads it so that it can be interrupted with Cancel.
+func (env *Env) load(thread *starlark.Thread, module string) (starlark.StringDict, error) {
+	path, src, err := env.findModule(thread, module)
+	if err != nil {
+		return nil, err
+	}
+
+	cache, _ := thread.Local(loadCacheName).(map[string]*loadEntry)
+	if cache == nil {
+		cache = make(map[string]*loadEntry)
+		thread.SetLocal(loadCacheName, cache)
+	}
+	e, ok := cache[path]
+	if ok {
+		if e == nil {
+			return nil, fmt.Errorf("cycle in load graph")
+		}
+		return e.globals, e.err
+	}
+	cache[path] = nil
+
+	globals, err := starlark.ExecFile(thread, path, src, env.env)
+	e = &loadEntry{globals, err}
+	cache[path] = e
+	return e.globals, e.err
+}
+
+// findModule returns the path and source of module.
+func (env *Env) findModule(thread *starlark.Thread, module string) (string, []byte, error) {
+	if filepath.IsAbs(module) {
+		src, err := ioutil.ReadFile(module)
+		return module, src, err
+	}
+
+	var dirs []string
+	if thread.CallStackDepth() > 0 {
+		if filename := thread.CallFrame(0).Pos.Filename(); filepath.IsAbs(filename) || fileExists(filename) {
+			dirs = append(dirs, filepath.Dir(filename))
+		}
+	}
+	dirs = append(dirs, env.ctx.ScriptPath()...)
+
+	for _, dir := range dirs {
+		path := filepath.Join(dir, module)
+		if !fileExists(path) {
+			continue
+		}
+		src, err := ioutil.ReadFile(path)
+		return path, src, err
+	}
+
+	// assets always use forward slashes
+	path := libraryDir + "/" + filepath.ToSlash(module)
+	if src, err := assets.Asset(path); err == nil {
+		return "<" + path + ">", src, nil
+	}
+
+	return "", nil, fmt.Errorf("module %q not found", module)
+}
+
+func fileExists(path string) bool {
+	fi, err := os.Stat(path)
+	return err == nil && !fi.IsDir()
+}
@@ -0,0 +1,177 @@
+package starbind
+
+import (
+	"fmt"
+	"reflect"
+
+	"go.starlark.net/starlark"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+)
+
+const (
+	// readMemoryChunkSize is the maximum number of bytes read by
+	// read_memory with a single request.
+	readMemoryChunkSize = 4096
+
+	// maxReadMemorySize is the maximum number of bytes read_memory can
+	// read.
+	maxReadMemorySize = 1 << 20
+
+	// layoutAddr is the address at which type_info places the variables
+	// it uses to compute the layout of types, the memory at this address
+	// is never used.
+	layoutAddr = 0x1000
+)
+
+// TypeInfo describes the layout of a type of the target program.
+type TypeInfo struct {
+	Name   string
+	Kind   string
+	Size   int64
+	Fields []FieldInfo
+}
+
+// FieldInfo describes a field of a struct type.
+type FieldInfo struct {
+	Name   string
+	Type   string
+	Kind   string
+	Offset int64
+	Size   int64
+}
+
+func (env *Env) readMemoryBuiltin(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := isCancelled(thread); err != nil {
+		return starlark.None, decorateError(thread, err)
+	}
+	if len(args) != 2 {
+		return nil, decorateError(thread, fmt.Errorf("wrong number of arguments"))
+	}
+	addr, err := starlarkUint(args[0], "first argument of read_memory")
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+	size, err := starlarkUint(args[1], "second argument of read_memory")
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+	if size > maxReadMemorySize {
+		return nil, decorateError(thread, fmt.Errorf("can not read more than %d bytes with read_memory", maxReadMemorySize))
+	}
+	buf, err := env.readMemory(uintptr(addr), int(size))
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+	r := make([]starlark.Value, len(buf))
+	for i := range buf {
+		r[i] = starlark.MakeInt(int(buf[i]))
+	}
+	return starlark.NewList(r), nil
+}
+
+// readMemory reads size bytes of memory of the target process starting at
+// addr.
+func (env *Env) readMemory(addr uintptr, size int) ([]byte, error) {
+	r := make([]byte, 0, size)
+	err := env.ctx.Client().ReadMemory(env.ctx.Scope(), addr, size, readMemoryChunkSize, func(_ int, buf []byte) error {
+		r = append(r, buf...)
+		return nil
+	})
+	return r, err
+}
+
+func (env *Env) typeInfoBuiltin(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := isCancelled(thread); err != nil {
+		return starlark.None, decorateError(thread, err)
+	}
+	if len(args) != 1 {
+		return nil, decorateError(thread, fmt.Errorf("wrong number of arguments"))
+	}
+	name, ok := args[0].(starlark.String)
+	if !ok {
+		return nil, decorateError(thread, fmt.Errorf("argument of type_info was not a string"))
+	}
+	ti, err := env.typeInfo(string(name))
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+	return env.interfaceToStarlarkValue(ti), nil
+}
+
+// typeInfo returns the layout of the type called name. Fields of structs
+// are described by placing a variable of the type at layoutAddr and
+// looking at the addresses of its fields.
+func (env *Env) typeInfo(name string) (*TypeInfo, error) {
+	v, err := env.ctx.Client().EvalVariable(env.ctx.Scope(), fmt.Sprintf("*(*%q)(%#x)", name, layoutAddr), api.LoadConfig{false, 0, 0, 0, -1})
+	if err != nil {
+		return nil, err
+	}
+	size, err := env.typeSize(name)
+	if err != nil {
+		return nil, err
+	}
+	ti := &TypeInfo{Name: v.Type, Kind: v.Kind.String(), Size: size}
+	if v.Kind != reflect.Struct {
+		return ti, nil
+	}
+	for i := range v.Children {
+		f := &v.Children[i]
+		ti.Fields = append(ti.Fields, FieldInfo{Name: f.Name, Type: f.Type, Kind: f.Kind.String(), Offset: int64(f.Addr - v.Addr)})
+	}
+	for i := range ti.Fields {
+		f := &ti.Fields[i]
+		f.Size, err = env.typeSize(f.Type)
+		if err != nil {
+			// the type of the field could not be found by name, use the
+			// distance to the next field which includes padding
+			end := ti.Size
+			if i+1 < len(ti.Fields) {
+				end = ti.Fields[i+1].Offset
+			}
+			f.Size = end - f.Offset
+		}
+	}
+	return ti, nil
+}
+
+// typeSize returns the size of the type called name.
+func (env *Env) typeSize(name string) (int64, error) {
+	return env.ctx.Client().TypeSize(env.ctx.Scope(), name)
+}
+
+func (env *Env) sizeofBuiltin(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := isCancelled(thread); err != nil {
+		return starlark.None, decorateError(thread, err)
+	}
+	if len(args) != 1 {
+		return nil, decorateError(thread, fmt.Errorf("wrong number of arguments"))
+	}
+	expr, ok := args[0].(starlark.String)
+	if !ok {
+		return nil, decorateError(thread, fmt.Errorf("argument of sizeof was not a string"))
+	}
+	v, err := env.ctx.Client().EvalVariable(env.ctx.Scope(), string(expr), api.LoadConfig{false, 0, 0, 0, 0})
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+	size, err := env.typeSize(v.Type)
+	if err != nil {
+		return nil, decorateError(thread, err)
+	}
+	return starlark.MakeInt64(size), nil
+}
+
+// starlarkUint converts v to an unsigned integer, what is used in error
+// messages.
+func starlarkUint(v starlark.Value, what string) (uint64, error) {
+	n, ok := v.(starlark.Int)
+	if !ok {
+		return 0, fmt.Errorf("%s was not an integer", what)
+	}
+	r, ok := n.Uint64()
+	if !ok {
+		return 0, fmt.Errorf("%s out of range", what)
+	}
+	return r, nil
+}
@@ -0,0 +1,168 @@
+package starbind
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"go.starlark.net/starlark"
+)
+
+// PanelContent is the content of a panel defined by a script, any
+// combination of text, table and tree can be specified.
+type PanelContent struct {
+	Text    string
+	Columns []string
+	Rows    [][]string
+	Tree    []*PanelNode
+}
+
+// PanelNode is a node of the tree displayed by a panel defined by a script.
+type PanelNode struct {
+	Name     string
+	Value    string
+	Children []*PanelNode
+}
+
+func (env *Env) panelBuiltin(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if len(args) != 2 {
+		return nil, decorateError(thread, fmt.Errorf("wrong number of arguments"))
+	}
+	name, ok := args[0].(starlark.String)
+	if !ok {
+		return nil, decorateError(thread, fmt.Errorf("first argument of panel was not a string"))
+	}
+	fn, ok := args[1].(starlark.Callable)
+	if !ok {
+		return nil, decorateError(thread, fmt.Errorf("second argument of panel was not a function"))
+	}
+	err := env.ctx.RegisterPanel(string(name), func(out io.Writer) (*PanelContent, error) {
+		// panels are rendered while other scripts may be running
+		thread, release := env.newBackgroundThread(out)
+		defer release()
+		v, err := starlark.Call(thread, fn, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		return toPanelContent(v)
+	})
+	return starlark.None, decorateError(thread, err)
+}
+
+// toPanelContent converts the value returned by the render function of a
+// panel. The value can be either a string or a dictionary with the
+// optional keys "text", "columns", "rows" and "tree".
+func toPanelContent(v starlark.Value) (*PanelContent, error) {
+	switch v := v.(type) {
+	case starlark.String:
+		return &PanelContent{Text: string(v)}, nil
+	case starlark.NoneType:
+		return &PanelContent{}, nil
+	case *starlark.Dict:
+		r := &PanelContent{}
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("key %s of panel content is not a string", item[0])
+			}
+			var err error
+			switch key {
+			case "text":
+				r.Text = panelString(item[1])
+			case "columns":
+				r.Columns, err = panelStrings(item[1])
+			case "rows":
+				var rows []starlark.Value
+				rows, err = panelList(item[1])
+				for _, row := range rows {
+					var cells []string
+					cells, err = panelStrings(row)
+					if err != nil {
+						break
+					}
+					r.Rows = append(r.Rows, cells)
+				}
+			case "tree":
+				r.Tree, err = toPanelNodes(item[1])
+			default:
+				return nil, fmt.Errorf("unknown key %q in panel content", key)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", key, err)
+			}
+		}
+		return r, nil
+	default:
+		return nil, fmt.Errorf("unsupported panel content %s", v.Type())
+	}
+}
+
+// toPanelNodes converts a list of tree nodes, each node is either a
+// string or a dictionary with the keys "name", "value" and "children".
+func toPanelNodes(v starlark.Value) ([]*PanelNode, error) {
+	list, err := panelList(v)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]*PanelNode, 0, len(list))
+	for _, elem := range list {
+		node := &PanelNode{}
+		switch elem := elem.(type) {
+		case *starlark.Dict:
+			for _, item := range elem.Items() {
+				switch item[0] {
+				case starlark.String("name"):
+					node.Name = panelString(item[1])
+				case starlark.String("value"):
+					node.Value = panelString(item[1])
+				case starlark.String("children"):
+					node.Children, err = toPanelNodes(item[1])
+					if err != nil {
+						return nil, err
+					}
+				default:
+					return nil, fmt.Errorf("unknown key %s in tree node", item[0])
+				}
+			}
+		default:
+			node.Value = panelString(elem)
+		}
+		r = append(r, node)
+	}
+	return r, nil
+}
+
+func panelList(v starlark.Value) ([]starlark.Value, error) {
+	it, ok := v.(starlark.Iterable)
+	if !ok {
+		return nil, errors.New("not a list")
+	}
+	var r []starlark.Value
+	iter := it.Iterate()
+	defer iter.Done()
+	var elem starlark.Value
+	for iter.Next(&elem) {
+		r = append(r, elem)
+	}
+	return r, nil
+}
+
+func panelStrings(v starlark.Value) ([]string, error) {
+	list, err := panelList(v)
+	if err != nil {
+		return nil, err
+	}
+	r := make([]string, len(list))
+	for i := range list {
+		r[i] = panelString(list[i])
+	}
+	return r, nil
+}
+
+// panelString converts v to a string, strings are not quoted.
+func panelString(v starlark.Value) string {
+	if s, ok := v.(starlark.String); ok {
+		return string(s)
+	}
+	return v.String()
+}
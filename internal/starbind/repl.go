@@ -36,6 +36,7 @@ package starbind
 import (
 	"fmt"
 	"io"
+	"strings"
 
 	"go.starlark.net/starlark"
 	"go.starlark.net/syntax"
@@ -48,11 +49,20 @@ func (env *Env) REPL(out io.Writer, lineReader <-chan string, promptChan chan<-
 		close(promptChan)
 	}()
 	env.out = out
-	thread := env.newThread()
+	thread, release := env.newScriptThread(out)
+	defer release()
 	globals := starlark.StringDict{}
 	for k, v := range env.env {
 		globals[k] = v
 	}
+	env.replMu.Lock()
+	env.replGlobals = globals
+	env.replMu.Unlock()
+	defer func() {
+		env.replMu.Lock()
+		env.replGlobals = nil
+		env.replMu.Unlock()
+	}()
 
 	for {
 		if err := isCancelled(thread); err != nil {
@@ -77,6 +87,8 @@ const (
 )
 
 // rep reads, evaluates, and prints one item.
+// A message read from lineReader containing newlines is a complete block
+// of code and is evaluated as a whole.
 //
 // It returns an error (possibly readline.ErrInterrupt)
 // only if readline failed. Starlark errors are printed.
@@ -84,8 +96,17 @@ func (env *Env) rep(lineReader <-chan string, prompt chan<- string, thread *star
 	eof := false
 
 	prompt <- normalPrompt
+	first := <-lineReader
+	if first == exitCommand {
+		return io.EOF
+	}
+	firstRead := false
 	readline := func() ([]byte, error) {
-		line := <-lineReader
+		line := first
+		if firstRead {
+			line = <-lineReader
+		}
+		firstRead = true
 		if line == exitCommand {
 			eof = true
 			return nil, io.EOF
@@ -95,7 +116,13 @@ func (env *Env) rep(lineReader <-chan string, prompt chan<- string, thread *star
 	}
 
 	// parse
-	f, err := syntax.ParseCompoundStmt("<stdin>", readline)
+	var f *syntax.File
+	var err error
+	if strings.Contains(first, "\n") {
+		f, err = syntax.Parse("<stdin>", first+"\n", 0)
+	} else {
+		f, err = syntax.ParseCompoundStmt("<stdin>", readline)
+	}
 	if err != nil {
 		if eof {
 			return io.EOF
@@ -134,9 +161,11 @@ func (env *Env) rep(lineReader <-chan string, prompt chan<- string, thread *star
 		// The global names from the previous call become
 		// the predeclared names of this call.
 		// If execution failed, some globals may be undefined.
+		env.replMu.Lock()
 		for k, v := range res {
 			globals[k] = v
 		}
+		env.replMu.Unlock()
 	}
 
 	return nil
@@ -160,37 +189,3 @@ func printError(out io.Writer, err error) {
 		fmt.Fprintln(out, err)
 	}
 }
-
-// MakeLoad returns a simple sequential implementation of module loading
-// suitable for use in the REPL.
-// Each function returned by MakeLoad accesses a distinct private cache.
-func MakeLoad() func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
-	type entry struct {
-		globals starlark.StringDict
-		err     error
-	}
-
-	var cache = make(map[string]*entry)
-
-	return func(thread *starlark.Thread, module string) (starlark.StringDict, error) {
-		e, ok := cache[module]
-		if e == nil {
-			if ok {
-				// request for package whose loading is in progress
-				return nil, fmt.Errorf("cycle in load graph")
-			}
-
-			// Add a placeholder to indicate "load in progress".
-			cache[module] = nil
-
-			// Load it.
-			thread := &starlark.Thread{Name: "exec " + module, Load: thread.Load}
-			globals, err := starlark.ExecFile(thread, module, nil, nil)
-			e = &entry{globals, err}
-
-			// Update the cache.
-			cache[module] = e
-		}
-		return e.globals, e.err
-	}
-}
@@ -27,8 +27,23 @@ const (
 	dlvContextName               = "dlv_context"
 	curScopeBuiltinName          = "cur_scope"
 	defaultLoadConfigBuiltinName = "default_load_config"
+	panelBuiltinName             = "panel"
+	registerFormatterBuiltinName = "register_formatter"
+	readMemoryBuiltinName        = "read_memory"
+	typeInfoBuiltinName          = "type_info"
+	sizeofBuiltinName            = "sizeof"
 )
 
+// Names of the functions that scripts can define to be notified of events.
+const (
+	OnStopHook       = "on_stop"
+	OnBreakpointHook = "on_breakpoint"
+	OnExitHook       = "on_exit"
+	OnRestartHook    = "on_restart"
+)
+
+var hookNames = map[string]bool{OnStopHook: true, OnBreakpointHook: true, OnExitHook: true, OnRestartHook: true}
+
 func init() {
 	resolve.AllowNestedDef = true
 	resolve.AllowLambda = true
@@ -44,9 +59,12 @@ func init() {
 type Context interface {
 	Client() *rpc2.RPCClient
 	RegisterCallback(name, helpMsg string, cmdfn func(args string) (starlark.Value, error))
+	RegisterPanel(name string, render func(out io.Writer) (*PanelContent, error)) error
+	RegisterFormatter(pattern string, fn func(v *api.Variable) (string, error)) error
 	CallCommand(cmdstr string) error
 	Scope() api.EvalScope
 	LoadConfig() api.LoadConfig
+	ScriptPath() []string
 }
 
 // Env is the environment used to evaluate starlark scripts.
@@ -55,6 +73,13 @@ type Env struct {
 	contextMu sync.Mutex
 	cancelfn  context.CancelFunc
 	thread    *starlark.Thread
+	bgThreads map[*starlark.Thread]context.CancelFunc // threads running hooks, panels and formatters
+
+	hooksMu sync.Mutex
+	hooks   map[string]*starlark.Function
+
+	replMu      sync.Mutex
+	replGlobals starlark.StringDict // globals of the running REPL, used for completion
 
 	ctx Context
 	out io.Writer
@@ -116,6 +141,11 @@ func New(ctx Context) *Env {
 	env.env[defaultLoadConfigBuiltinName] = starlark.NewBuiltin(defaultLoadConfigBuiltinName, func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 		return env.interfaceToStarlarkValue(env.ctx.LoadConfig()), nil
 	})
+	env.env[panelBuiltinName] = starlark.NewBuiltin(panelBuiltinName, env.panelBuiltin)
+	env.env[registerFormatterBuiltinName] = starlark.NewBuiltin(registerFormatterBuiltinName, env.registerFormatterBuiltin)
+	env.env[readMemoryBuiltinName] = starlark.NewBuiltin(readMemoryBuiltinName, env.readMemoryBuiltin)
+	env.env[typeInfoBuiltinName] = starlark.NewBuiltin(typeInfoBuiltinName, env.typeInfoBuiltin)
+	env.env[sizeofBuiltinName] = starlark.NewBuiltin(sizeofBuiltinName, env.sizeofBuiltin)
 	return env
 }
 
@@ -146,7 +176,8 @@ func (env *Env) Execute(out io.Writer, path string, source interface{}, mainFnNa
 	}()
 
 	env.out = out
-	thread := env.newThread()
+	thread, release := env.newScriptThread(out)
+	defer release()
 
 	envenv := env.env
 	if v != nil {
@@ -173,6 +204,15 @@ func (env *Env) Execute(out io.Writer, path string, source interface{}, mainFnNa
 			if err != nil {
 				return starlark.None, err
 			}
+		case hookNames[name]:
+			if fnval, ok := val.(*starlark.Function); ok {
+				env.hooksMu.Lock()
+				if env.hooks == nil {
+					env.hooks = make(map[string]*starlark.Function)
+				}
+				env.hooks[name] = fnval
+				env.hooksMu.Unlock()
+			}
 		case name[0] >= 'A' && name[0] <= 'Z':
 			env.env[name] = val
 		}
@@ -181,38 +221,109 @@ func (env *Env) Execute(out io.Writer, path string, source interface{}, mainFnNa
 	return env.callMain(thread, globals, mainFnName, args)
 }
 
+// HasHook returns true if a script defined the hook function name.
+func (env *Env) HasHook(name string) bool {
+	env.hooksMu.Lock()
+	defer env.hooksMu.Unlock()
+	return env.hooks[name] != nil
+}
+
+// CallHook calls the hook function name, if one was defined, converting
+// args to starlark values. Hooks run on their own thread, the call can be
+// interrupted with Cancel only if no other script is running.
+func (env *Env) CallHook(out io.Writer, name string, args ...interface{}) error {
+	env.hooksMu.Lock()
+	fnval := env.hooks[name]
+	env.hooksMu.Unlock()
+	if fnval == nil {
+		return nil
+	}
+	if fnval.NumParams() != len(args) {
+		return fmt.Errorf("wrong number of arguments for %s, expected %d", name, len(args))
+	}
+	argtuple := make(starlark.Tuple, len(args))
+	for i := range args {
+		argtuple[i] = env.interfaceToStarlarkValue(args[i])
+	}
+	thread, release := env.newBackgroundThread(out)
+	defer release()
+	_, err := starlark.Call(thread, fnval, argtuple, nil)
+	return err
+}
+
 // Cancel cancels the execution of a currently running script or function.
+// If no script is running the hooks, panels and formatters currently
+// running are cancelled instead.
 func (env *Env) Cancel() {
 	if env == nil {
 		return
 	}
 	env.contextMu.Lock()
+	defer env.contextMu.Unlock()
+	if env.thread == nil {
+		for thread, cancelfn := range env.bgThreads {
+			cancelfn()
+			thread.Cancel("user interrupt")
+		}
+		return
+	}
 	if env.cancelfn != nil {
 		env.cancelfn()
 		env.cancelfn = nil
 	}
-	if env.thread != nil {
-		env.thread.Cancel("user interrupt")
+	env.thread.Cancel("user interrupt")
+}
+
+// newScriptThread returns a new thread that prints to out, until release
+// is called the thread is the one interrupted by Cancel.
+func (env *Env) newScriptThread(out io.Writer) (thread *starlark.Thread, release func()) {
+	thread, cancelfn := env.makeThread(out)
+	env.contextMu.Lock()
+	prevThread, prevCancelfn := env.thread, env.cancelfn
+	env.thread, env.cancelfn = thread, cancelfn
+	env.contextMu.Unlock()
+	return thread, func() {
+		cancelfn()
+		env.contextMu.Lock()
+		if env.thread == thread {
+			env.thread, env.cancelfn = prevThread, prevCancelfn
+		}
+		env.contextMu.Unlock()
+	}
+}
+
+// newBackgroundThread returns a new thread that prints to out, used for
+// functions that run concurrently with the scripts started by the user.
+// Background threads do not replace the thread interrupted by Cancel.
+func (env *Env) newBackgroundThread(out io.Writer) (thread *starlark.Thread, release func()) {
+	thread, cancelfn := env.makeThread(out)
+	env.contextMu.Lock()
+	if env.bgThreads == nil {
+		env.bgThreads = make(map[*starlark.Thread]context.CancelFunc)
 	}
+	env.bgThreads[thread] = cancelfn
 	env.contextMu.Unlock()
+	return thread, func() {
+		cancelfn()
+		env.contextMu.Lock()
+		delete(env.bgThreads, thread)
+		env.contextMu.Unlock()
+	}
 }
 
-func (env *Env) newThread() *starlark.Thread {
+func (env *Env) makeThread(out io.Writer) (*starlark.Thread, context.CancelFunc) {
 	thread := &starlark.Thread{
+		Load: env.load,
 		Print: func(thread *starlark.Thread, msg string) {
 			if err := isCancelled(thread); err != nil {
 				panic("cancelled")
 			}
-			fmt.Fprintln(env.out, msg)
+			fmt.Fprintln(out, msg)
 		},
 	}
-	env.contextMu.Lock()
-	var ctx context.Context
-	env.thread = thread
-	ctx, env.cancelfn = context.WithCancel(context.Background())
-	env.contextMu.Unlock()
+	ctx, cancelfn := context.WithCancel(context.Background())
 	thread.SetLocal(dlvContextName, ctx)
-	return thread
+	return thread, cancelfn
 }
 
 func (env *Env) createCallback(name string, val starlark.Value) error {
@@ -231,14 +342,17 @@ func (env *Env) createCallback(name string, val starlark.Value) error {
 	if fnval.NumParams() == 1 {
 		if p0, _ := fnval.Param(0); p0 == "args" {
 			env.ctx.RegisterCallback(name, helpMsg, func(args string) (starlark.Value, error) {
-				return starlark.Call(env.newThread(), fnval, starlark.Tuple{starlark.String(args)}, nil)
+				thread, release := env.newScriptThread(env.out)
+				defer release()
+				return starlark.Call(thread, fnval, starlark.Tuple{starlark.String(args)}, nil)
 			})
 			return nil
 		}
 	}
 
 	env.ctx.RegisterCallback(name, helpMsg, func(args string) (starlark.Value, error) {
-		thread := env.newThread()
+		thread, release := env.newScriptThread(env.out)
+		defer release()
 		argval, err := starlark.Eval(thread, "<input>", "("+args+")", env.env)
 		if err != nil {
 			return starlark.None, err
@@ -0,0 +1,67 @@
+package starbind
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+const testPrefix = "test_"
+
+// RunTests executes the script at path and then calls all the functions it
+// defines whose name starts with "test_", in the order they are defined.
+// Before each test setup is called, if it fails the test is not run.
+// Results are written to out, RunTests returns the number of failed tests.
+func (env *Env) RunTests(out io.Writer, path string, setup func() error) (int, error) {
+	env.out = out
+	thread, release := env.newScriptThread(out)
+	globals, err := starlark.ExecFile(thread, path, nil, env.env)
+	release()
+	if err != nil {
+		return 0, err
+	}
+
+	var tests []*starlark.Function
+	for name, val := range globals {
+		if fnval, ok := val.(*starlark.Function); ok && strings.HasPrefix(name, testPrefix) {
+			tests = append(tests, fnval)
+		}
+	}
+	sort.Slice(tests, func(i, j int) bool {
+		return tests[i].Position().Line < tests[j].Position().Line
+	})
+
+	failed := 0
+	for _, fnval := range tests {
+		fmt.Fprintf(out, "=== RUN   %s\n", fnval.Name())
+		t0 := time.Now()
+		err := setup()
+		if err == nil {
+			if fnval.NumParams() != 0 {
+				err = fmt.Errorf("%s: test functions must not have parameters", fnval.Position())
+			} else {
+				thread, release := env.newScriptThread(out)
+				_, err = starlark.Call(thread, fnval, nil, nil)
+				release()
+			}
+		}
+		d := time.Since(t0).Seconds()
+		if err != nil {
+			failed++
+			fmt.Fprintf(out, "--- FAIL: %s (%.2fs)\n", fnval.Name(), d)
+			printError(out, err)
+		} else {
+			fmt.Fprintf(out, "--- PASS: %s (%.2fs)\n", fnval.Name(), d)
+		}
+	}
+	if failed > 0 {
+		fmt.Fprintf(out, "FAIL\n")
+	} else {
+		fmt.Fprintf(out, "PASS\n")
+	}
+	return failed, nil
+}
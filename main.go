@@ -28,7 +28,7 @@ import (
 	"golang.org/x/mobile/event/key"
 )
 
-//go:generate go-bindata -o internal/assets/assets.go -pkg assets fontawesome-webfont.ttf droid-sans.bold.ttf codicon.ttf
+//go:generate go-bindata -o internal/assets/assets.go -pkg assets fontawesome-webfont.ttf droid-sans.bold.ttf codicon.ttf starlib/...
 
 const profileEnabled = false
 
@@ -47,11 +47,14 @@ var codiconFontData []byte
 var (
 	linkColor      = color.RGBA{0x00, 0x88, 0xdd, 0xff}
 	linkHoverColor = color.RGBA{0x00, 0xaa, 0xff, 0xff}
+	errorColor     = color.RGBA{0xff, 0x00, 0x00, 0xff}
 )
 
 const (
 	arrowIconChar      = "\uf061"
 	breakpointIconChar = "\uf28d"
+	caretRightIconChar = "\uf0da"
+	caretDownIconChar  = "\uf0d7"
 
 	interruptIconChar = "\uEAD1"
 	continueIconChar  = "\uEACF"
@@ -154,6 +157,7 @@ func setupStyle() {
 }
 
 const commandLineHeight = 28
+const maxCommandLines = 10
 
 type listline struct {
 	idx          string
@@ -184,9 +188,31 @@ var listingPanel struct {
 	disassHoverIdx      int
 	disassHoverClickIdx int
 	centerOnDisassHover bool
+
+	jumps            []disassemblyJump
+	jumpLanes        int
+	disassHistory    []uint64 // PCs visited by following jumps in the disassembly panel
+	disassHistoryPos int
+}
+
+// masterWindow is the part of nucular.MasterWindow used by gdlv, it is
+// also implemented by headlessWindow.
+type masterWindow interface {
+	Main()
+	Changed()
+	Close()
+	OnClose(func())
+	Style() *nstyle.Style
+	SetStyle(*nstyle.Style)
+	Input() *nucular.Input
+	PopupOpen(title string, flags nucular.WindowFlags, rect rect.Rect, scale bool, updateFn nucular.UpdateFn)
+	Walk(nucular.WindowWalkFn)
+	ResetWindows() *nucular.DockSplit
+	Lock()
+	Unlock()
 }
 
-var wnd nucular.MasterWindow
+var wnd masterWindow
 
 var nextInProgress bool
 var client *rpc2.RPCClient
@@ -215,6 +241,8 @@ func guiUpdate(w *nucular.Window) {
 
 	mw := w.Master()
 
+	addPendingScriptPanels()
+
 	for _, e := range wnd.Input().Keyboard.Keys {
 		switch {
 		case (e.Modifiers&key.ModControl != 0) && e.Code == key.CodeEqualSign:
@@ -359,8 +387,23 @@ func currentPrompt() string {
 func updateCommandPanel(w *nucular.Window) {
 	style := w.Master().Style()
 
+	// blocks entered in the starlark REPL can span multiple lines
+	cmdlineHeight := int(commandLineHeight * style.Scaling)
+	if starlarkMode != nil {
+		commandLineEditor.Flags |= nucular.EditMultiline | nucular.EditCtrlEnterNewline
+		lines := 1
+		for _, ch := range commandLineEditor.Buffer {
+			if ch == '\n' && lines < maxCommandLines {
+				lines++
+			}
+		}
+		cmdlineHeight += (lines - 1) * nucular.FontHeight(style.Font)
+	} else {
+		commandLineEditor.Flags &^= nucular.EditMultiline | nucular.EditCtrlEnterNewline
+	}
+
 	w.Row(headerRow).Static()
-	w.LayoutReserveRow(commandLineHeight, 1)
+	w.LayoutReserveRowScaled(cmdlineHeight, 1)
 	commandToolbar(w)
 
 	w.Row(0).Dynamic(1)
@@ -388,8 +431,12 @@ func updateCommandPanel(w *nucular.Window) {
 
 	promptwidth := nucular.FontWidth(style.Font, p2) + style.Text.Padding.X*2
 
-	w.Row(commandLineHeight).StaticScaled(promptwidth, 0)
-	w.Label(p2, "LC")
+	w.RowScaled(cmdlineHeight).StaticScaled(promptwidth, 0)
+	if cmdlineHeight > int(commandLineHeight*style.Scaling) {
+		w.Label(p2, "LT")
+	} else {
+		w.Label(p2, "LC")
+	}
 
 	if client.Running() {
 		//commandLineEditor.Flags |= nucular.EditReadOnly
@@ -402,17 +449,20 @@ func updateCommandPanel(w *nucular.Window) {
 	if commandLineEditor.Active {
 		showHistory := false
 		kbd := &w.Input().Keyboard
+		// in multiline blocks the arrow keys move between lines
+		onFirstLine := !strings.ContainsRune(string(commandLineEditor.Buffer[:commandLineEditor.Cursor]), '\n')
+		onLastLine := !strings.ContainsRune(string(commandLineEditor.Buffer[commandLineEditor.Cursor:]), '\n')
 		for _, k := range kbd.Keys {
 			switch {
 			case k.Modifiers == 0 && k.Code == key.CodeTab:
 				historySearch = false
 				w.Input().Keyboard.Text = ""
 				completeAny()
-			case k.Modifiers == 0 && k.Code == key.CodeUpArrow:
+			case k.Modifiers == 0 && k.Code == key.CodeUpArrow && onFirstLine:
 				historySearch = false
 				historyShown--
 				showHistory = true
-			case k.Modifiers == 0 && k.Code == key.CodeDownArrow:
+			case k.Modifiers == 0 && k.Code == key.CodeDownArrow && onLastLine:
 				historySearch = false
 				historyShown++
 				showHistory = true
@@ -461,7 +511,12 @@ func updateCommandPanel(w *nucular.Window) {
 			fmt.Fprintf(&scrollbackOut, "a script is running\n")
 		} else if starlarkMode != nil {
 			cmdhistory = append(cmdhistory, cmd)
-			fmt.Fprintf(&scrollbackOut, "%s %s\n", p, cmd)
+			historyShown = len(cmdhistory)
+			if strings.TrimSpace(cmd) != "" {
+				appendStarlarkHistory(cmd)
+			}
+			fmt.Fprintf(&scrollbackOut, "%s %s\n", p, strings.Replace(cmd, "\n", "\n... ", -1))
+			// multiline blocks are sent as a single message
 			starlarkMode <- cmd
 		} else if canExecuteCmd(cmd) && !client.Running() {
 			if cmd == "" {
@@ -598,6 +653,9 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 		fmt.Fprintf(&scrollbackOut, "Error refreshing state %s: %v\n", pos, err)
 	}
 
+	// the target ran if we were passed its state
+	stopped := state != nil && clearKind == clearStop
+
 	if state == nil {
 		var err error
 		state, err = client.GetState()
@@ -607,7 +665,9 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 			curGid = -1
 			curFrame = 0
 			curDeferredCall = 0
-			if !strings.Contains(err.Error(), " has exited with status ") {
+			if status, exited := parseExitStatus(err); exited {
+				go runExitHook(status)
+			} else {
 				failstate("GetState()", err)
 			}
 
@@ -659,16 +719,22 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 		stackPanel.asyncLoad.clear()
 		localsPanel.asyncLoad.clear()
 		regsPanel.asyncLoad.clear()
+		ancestorsPanel.asyncLoad.clear()
 		listingPanel.pinnedLoc = nil
 	case clearStop:
 		localsPanel.asyncLoad.clear()
+		regsPanel.stopped = true
 		regsPanel.asyncLoad.clear()
 		goroutinesPanel.asyncLoad.clear()
 		stackPanel.asyncLoad.clear()
+		ancestorsPanel.asyncLoad.clear()
 		threadsPanel.asyncLoad.clear()
 		globalsPanel.asyncLoad.clear()
 		breakpointsPanel.asyncLoad.clear()
 		checkpointsPanel.asyncLoad.clear()
+		clearScriptPanels()
+		clearScriptFormatterCache()
+		clearScopeGoroutines()
 		listingPanel.pinnedLoc = nil
 		silenced = false
 
@@ -682,6 +748,12 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 		if bpcount > 1 {
 			fmt.Fprintf(&scrollbackOut, "Simultaneously stopped on %d goroutines!\n", bpcount)
 		}
+
+		if state.Exited {
+			go runExitHook(state.ExitStatus)
+		} else if stopped {
+			go runStopHooks(state)
+		}
 	}
 
 	loc := listingPanel.pinnedLoc
@@ -764,6 +836,13 @@ func refreshState(toframe refreshToFrame, clearKind clearKind, state *api.Debugg
 
 	curPC = loc.PC
 
+	if clearKind == clearStop {
+		// the values loaded for the pinned expressions by the next call to
+		// loadLocals are recorded in their history
+		historyLoc := *loc
+		localsPanel.historyLoc = &historyLoc
+	}
+
 	listingPanel.id++
 
 	listingPanel.text = nil
@@ -807,14 +886,54 @@ func loadDisassembly(p *asyncLoad) {
 		}
 
 		listingPanel.text = wrapInstructions(text, loc.PC)
+		listingPanel.jumps, listingPanel.jumpLanes = disassemblyJumps(listingPanel.text)
+		symbolizeInstructions(listingPanel.text)
+		if len(listingPanel.disassHistory) == 0 || findInstruction(listingPanel.disassHistory[0]) < 0 {
+			// navigation history is only kept while the same function is displayed
+			listingPanel.disassHistory = listingPanel.disassHistory[:0]
+			listingPanel.disassHistoryPos = -1
+		}
 		listingPanel.framePC = loc.PC
+
+		disassemblyPanel.sources = map[string][]string{}
+		if disassemblyPanel.interleaved {
+			for _, instr := range listingPanel.text {
+				if _, ok := disassemblyPanel.sources[instr.Loc.File]; ok {
+					continue
+				}
+				// files that can not be read are recorded as nil so that
+				// they are only tried once.
+				disassemblyPanel.sources[instr.Loc.File], _ = readSourceLines(instr.Loc.File)
+			}
+		}
 	} else {
 		listingPanel.text = nil
+		listingPanel.jumps, listingPanel.jumpLanes = nil, 0
 		listingPanel.framePC = 0
 	}
 	p.done(nil)
 }
 
+// readSourceLines reads the source file, applying the same path
+// substitution rules used by the listing panel.
+func readSourceLines(file string) ([]string, error) {
+	if file == "<autogenerated>" {
+		return nil, nil
+	}
+	fh, err := os.Open(conf.substitutePath(file))
+	if err != nil {
+		return nil, err
+	}
+	defer fh.Close()
+
+	r := []string{}
+	buf := bufio.NewScanner(fh)
+	for buf.Scan() {
+		r = append(r, expandTabs(buf.Text()))
+	}
+	return r, buf.Err()
+}
+
 func loadListing(loc *api.Location, failstate func(string, error)) {
 	listingPanel.listing = listingPanel.listing[:0]
 	listingPanel.recenterListing = true
@@ -943,6 +1062,7 @@ func usage(err string) {
 	gdlv [options] attach <pid> [path to executable]
 	gdlv [options] core <executable> <core file>
 	gdlv [options] replay <trace directory>
+	gdlv [options] test-script <script.star> <command...>
 	
 All commands except "core" and "replay" can be prefixed with the name of a backend, for example:
 
@@ -950,6 +1070,13 @@ All commands except "core" and "replay" can be prefixed with the name of a backe
 	
 Executes "gdlv run" using mozilla rr has a backend.
 
+The "test-script" command runs all functions starting with "test_" defined
+in the script against the target specified by the command that follows it,
+without opening a window. The target is restarted before each test, so
+it can not be used with "attach" and "core", for example:
+
+	gdlv test-script helpers_test.star replay <trace directory>
+
 Options must appear before the command and include:
 
 	-d <dir>	builds inside the specified directory instead of the current directory (for debug and test)
@@ -1016,7 +1143,7 @@ type commandLineOptions struct {
 }
 
 func main() {
-	if runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" {
+	if runtime.GOOS == "linux" && os.Getenv("DISPLAY") == "" && parseOptions(os.Args).cmd != testScriptCommand {
 		fmt.Fprintf(os.Stderr, "DISPLAY not set\n")
 		os.Exit(1)
 	}
@@ -1045,6 +1172,10 @@ func main() {
 
 	BackendServer = parseArguments()
 
+	if TestScript != "" {
+		os.Exit(runScriptTests())
+	}
+
 	if BackendServer.debugid != "" && conf.FrozenBreakpoints != nil && conf.DisabledBreakpoints != nil {
 		FrozenBreakpoints = append(FrozenBreakpoints[:0], conf.FrozenBreakpoints[BackendServer.debugid]...)
 		DisabledBreakpoints = append(DisabledBreakpoints[:0], conf.DisabledBreakpoints[BackendServer.debugid]...)
@@ -1073,6 +1204,7 @@ To change font size use Ctrl-plus/Ctrl-minus or the 'config zoom' command.
 	cmds = DebugCommands()
 
 	executeInit()
+	executeInitDir()
 
 	go BackendServer.Start()
 
@@ -1,10 +1,32 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"io/ioutil"
+	"math"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/aarzilli/nucular/rect"
 
 	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/rpc2"
 	"github.com/aarzilli/gdlv/internal/prettyprint"
+	"github.com/aarzilli/gdlv/internal/starbind"
+
+	"go.starlark.net/starlark"
 )
 
 func TestShortenType(t *testing.T) {
@@ -64,3 +86,965 @@ func TestWrapInstruction(t *testing.T) {
 	c("rex.w blah", "rex.w blah", "")
 	c("rex.w blah arg1", "rex.w blah", "arg1")
 }
+
+func TestDisassemblyJumps(t *testing.T) {
+	text := make([]wrappedInstruction, 10)
+	for i := range text {
+		text[i].dstidx = -1
+	}
+	text[1].dstidx = 8 // long jump
+	text[2].dstidx = 4 // nested inside 1->8
+	text[5].dstidx = 3 // overlaps 2->4
+	text[6].dstidx = 7 // disjoint from 2->4 and 5->3
+	text[9].dstidx = 9 // jump to itself, ignored
+
+	jumps, lanes := disassemblyJumps(text)
+	if len(jumps) != 4 {
+		t.Fatalf("wrong number of jumps: %v", jumps)
+	}
+	lane := map[int]int{}
+	for _, j := range jumps {
+		lane[j.src] = j.lane
+	}
+	if lane[6] != 0 || lane[2] != 0 || lane[5] != 1 || lane[1] != 2 {
+		t.Errorf("wrong lane assignment: %v", jumps)
+	}
+	if lanes != 3 {
+		t.Errorf("wrong number of lanes: %d", lanes)
+	}
+}
+
+func TestFindGlobalSymbol(t *testing.T) {
+	globals := sortGlobalsByAddress([]api.Variable{
+		{Name: "main.b", Type: "main.T", Addr: 0x5000},
+		{Name: "main.a", Type: "[32]uint8", Addr: 0x4000},
+		{Name: "main.c", Type: "main.unknown", Addr: 0x6000},
+		{Name: "main.unused", Addr: 0},
+	})
+	sizes := map[string]int64{"main.T": 16, "[32]uint8": 32}
+	sizeof := func(typ string) int64 { return sizes[typ] }
+	c := func(addr uint64, tgt string) {
+		out := findGlobalSymbol(globals, addr, sizeof)
+		if out != tgt {
+			t.Errorf("for %#x expected %q got %q", addr, tgt, out)
+		}
+	}
+	c(0x3fff, "")
+	c(0x4000, "main.a")
+	c(0x4010, "main.a+0x10")
+	c(0x4020, "")
+	c(0x5008, "main.b+0x8")
+	c(0x5010, "")
+	c(0x6000, "main.c")
+	c(0x6001, "")
+}
+
+func TestRegisterFormatting(t *testing.T) {
+	if s := decodeFlags(0x246); s != "[PF ZF IF IOPL=0]" {
+		t.Errorf("wrong flags decoding: %q", s)
+	}
+
+	b, ok := registerBytes("0x0000000200000001ffffffff3f800000\tv4_int={...}")
+	if !ok {
+		t.Fatalf("could not parse register value")
+	}
+	if s := formatVectorLanes(b, vectorInt32); s != "{1065353216 -1 1 2}" {
+		t.Errorf("wrong int32 lanes: %q", s)
+	}
+	if s := formatVectorLanes(b, vectorFloat32); s != "{1 NaN 1e-45 3e-45}" {
+		t.Errorf("wrong float32 lanes: %q", s)
+	}
+	if s := formatVectorLanes(b, vectorInt64); s != "{-3229614080 8589934593}" {
+		t.Errorf("wrong int64 lanes: %q", s)
+	}
+
+	for _, tc := range []struct {
+		val, tgt string
+	}{
+		{"0x3fff8000000000000000", "1"},
+		{"0xc000a000000000000000", "-2.5"},
+		{"0x00000000000000000000", "0"},
+		{"0x7fff8000000000000000", "+Inf"},
+		{"0x7fffc000000000000000", "NaN"},
+	} {
+		if _, descr := describeRegister("ST(0)", tc.val); descr != tc.tgt {
+			t.Errorf("wrong x87 value for %s: %q expected %q", tc.val, descr, tc.tgt)
+		}
+	}
+}
+
+func TestExprHistory(t *testing.T) {
+	saved := conf.ExprHistorySize
+	defer func() { conf.ExprHistorySize = saved }()
+	conf.ExprHistorySize = 3
+
+	h := &exprHistory{}
+	for i := 0; i < 5; i++ {
+		h.add(api.Location{File: "main.go", Line: 10 + i, PC: 0x1000}, fmt.Sprintf("%d", i))
+	}
+	if len(h.entries) != 3 || h.entries[0].Value != "2" || h.entries[2].Value != "4" {
+		t.Fatalf("wrong history: %v", h.entries)
+	}
+
+	var buf bytes.Buffer
+	if err := h.writeCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 || !strings.HasSuffix(lines[1], ",main.go,12,0x1000,2") {
+		t.Errorf("wrong CSV output:\n%s", buf.String())
+	}
+}
+
+func TestVariableFilter(t *testing.T) {
+	port := &Variable{Variable: &api.Variable{Kind: reflect.Int}, DisplayName: "Port", Value: "8080"}
+	host := &Variable{Variable: &api.Variable{Kind: reflect.String}, DisplayName: "Host", Value: "localhost"}
+	server := &Variable{Variable: &api.Variable{Kind: reflect.Struct}, DisplayName: "Server", Children: []*Variable{port, host}}
+	cfg := &Variable{Variable: &api.Variable{Kind: reflect.Struct}, DisplayName: "cfg", Children: []*Variable{server}}
+	other := &Variable{Variable: &api.Variable{Kind: reflect.Int}, DisplayName: "n", Value: "1"}
+
+	c := func(needle string, isRegexp bool, visible []*Variable, open []*Variable) {
+		f, err := newVariableFilter(needle, isRegexp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, v := range []*Variable{cfg, other} {
+			f.visit(v, v.DisplayName, false, 0)
+		}
+		for _, v := range []*Variable{cfg, server, port, host, other} {
+			if f.visible[v] != containsVariable(visible, v) {
+				t.Errorf("%q: wrong visibility for %s: %v", needle, v.DisplayName, f.visible[v])
+			}
+			if f.open[v] != containsVariable(open, v) {
+				t.Errorf("%q: wrong open state for %s: %v", needle, v.DisplayName, f.open[v])
+			}
+		}
+	}
+
+	c("Port", false, []*Variable{cfg, server, port}, []*Variable{cfg, server})
+	c("cfg.Server.Host", false, []*Variable{cfg, server, host}, []*Variable{cfg, server})
+	c("Server", false, []*Variable{cfg, server, port, host}, []*Variable{cfg})
+	c("^80[0-9]+$", true, []*Variable{cfg, server, port}, []*Variable{cfg, server})
+	c("nothing", false, nil, nil)
+
+	if _, err := newVariableFilter("a(", true); err == nil {
+		t.Errorf("no error for invalid regexp")
+	}
+	if _, err := newVariableFilter("a(", false); err != nil {
+		t.Errorf("error for substring filter: %v", err)
+	}
+}
+
+func containsVariable(vs []*Variable, v *Variable) bool {
+	for _, x := range vs {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExport(t *testing.T) {
+	v := &api.Variable{Name: "x", Type: "main.T", RealType: "main.T", Kind: reflect.Struct, Len: 3, Children: []api.Variable{
+		{Name: "S", Type: "string", Kind: reflect.String, Value: "a\"b\n", Len: 4},
+		{Name: "N", Type: "int", Kind: reflect.Int, Value: "-5"},
+		{Name: "L", Type: "[]int", Kind: reflect.Slice, Len: 3, Cap: 3, Children: []api.Variable{
+			{Type: "int", Kind: reflect.Int, Value: "1"},
+		}},
+	}}
+	c := func(name, out, tgt string) {
+		if out != tgt {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", name, tgt, out)
+		}
+	}
+	c("JSON", prettyprint.JSON(v), "{\n\t\"S\": \"a\\\"b\\n\",\n\t\"N\": -5,\n\t\"L\": [\n\t\t1,\n\t\t\"...+2 more\"\n\t]\n}")
+	c("YAML", prettyprint.YAML(v), "\"S\": \"a\\\"b\\n\"\n\"N\": -5\n\"L\":\n- 1\n- \"...+2 more\"")
+	c("Go", prettyprint.GoLiteral(v), "main.T{\n\tS: \"a\\\"b\\n\",\n\tN: -5,\n\tL: []int{1 /* ...+2 more */},\n}")
+
+	// children loaded after the first load are exported
+	wv := wrapApiVariable(v, "x", "x", false, 0)
+	l := wv.Children[2]
+	l.Children = append(l.Children, wrapApiVariables([]api.Variable{{Type: "int", Kind: reflect.Int, Value: "2"}}, reflect.Slice, 1, l.Expression, false, 0)...)
+	c("JSON (loaded more)", prettyprint.JSON(wv.apiVariable()), "{\n\t\"S\": \"a\\\"b\\n\",\n\t\"N\": -5,\n\t\"L\": [\n\t\t1,\n\t\t2,\n\t\t\"...+1 more\"\n\t]\n}")
+
+	m := &api.Variable{Name: "m", Type: "map[string]int", Kind: reflect.Map, Len: 1, Children: []api.Variable{
+		{Type: "string", Kind: reflect.String, Value: "a", Len: 1},
+		{Type: "int", Kind: reflect.Int, Value: "1"},
+	}}
+	if out, tgt := prettyprint.JSON(wrapApiVariable(m, "m", "m", false, 0).apiVariable()), prettyprint.JSON(m); out != tgt {
+		t.Errorf("map export: expected:\n%s\ngot:\n%s", tgt, out)
+	}
+}
+
+func TestDiffVariables(t *testing.T) {
+	mk := func(name string, n string, elems ...string) *api.Variable {
+		l := api.Variable{Name: "L", Type: "[]int", Kind: reflect.Slice, Len: int64(len(elems)), Cap: int64(len(elems))}
+		for _, e := range elems {
+			l.Children = append(l.Children, api.Variable{Type: "int", Kind: reflect.Int, Value: e})
+		}
+		return &api.Variable{Name: "x", Type: "main.T", Kind: reflect.Struct, Len: 3, Children: []api.Variable{
+			{Name: "S", Type: "string", Kind: reflect.String, Value: name, Len: int64(len(name))},
+			{Name: "N", Type: "int", Kind: reflect.Int, Value: n},
+			l,
+		}}
+	}
+
+	if d := diffVariables("x", mk("a", "1", "1", "2"), mk("a", "1", "1", "2")); len(d) != 0 {
+		t.Errorf("unexpected differences %v", d)
+	}
+
+	d := diffVariables("x", mk("a", "1", "1", "2"), mk("b", "1", "1", "3", "4"))
+	tgt := []variableDiff{
+		{Path: "x.S", Kind: diffChanged, Old: `"a"`, New: `"b"`},
+		{Path: "x.L[1]", Kind: diffChanged, Old: "2", New: "3"},
+		{Path: "x.L[2]", Kind: diffAdded, New: "4"},
+	}
+	if !reflect.DeepEqual(d, tgt) {
+		t.Errorf("expected %v got %v", tgt, d)
+	}
+
+	for _, tc := range []struct{ in, a, b string }{
+		{"a b", "a", "b"},
+		{"a.b[1] c + d", "a.b[1]", "c + d"},
+		{"a - b c", "a - b", "c"},
+		{"#1 x", "#1", "x"},
+		{"@g1 x @g2 x", "@g1 x", "@g2 x"},
+	} {
+		a, b, err := splitDiffArgs(tc.in)
+		if err != nil || a != tc.a || b != tc.b {
+			t.Errorf("%q: expected %q %q got %q %q %v", tc.in, tc.a, tc.b, a, b, err)
+		}
+	}
+}
+
+func TestStructTable(t *testing.T) {
+	mk := func(name string, age string, city string) api.Variable {
+		return api.Variable{Type: "main.Person", Kind: reflect.Struct, Children: []api.Variable{
+			{Name: "Name", Type: "string", Kind: reflect.String, Value: name, Len: int64(len(name))},
+			{Name: "Age", Type: "int", Kind: reflect.Int, Value: age},
+			{Name: "Addr", Type: "main.Address", Kind: reflect.Struct, Children: []api.Variable{
+				{Name: "City", Type: "string", Kind: reflect.String, Value: city, Len: int64(len(city))},
+			}},
+		}}
+	}
+	tbl := newStructTable([]api.Variable{mk("bob", "30", "Rome"), mk("alice", "4", "Paris"), mk("carol", "12", "Rome")})
+	if tgt := []string{"Name", "Age", "Addr.City"}; !reflect.DeepEqual(tbl.columns, tgt) {
+		t.Errorf("expected columns %v got %v", tgt, tbl.columns)
+	}
+
+	order := []int{0, 1, 2}
+	tbl.sortRows(order, 1, false)
+	if tgt := []int{1, 2, 0}; !reflect.DeepEqual(order, tgt) {
+		t.Errorf("sort by age: expected %v got %v", tgt, order)
+	}
+	tbl.sortRows(order, 0, true)
+	if tgt := []int{2, 0, 1}; !reflect.DeepEqual(order, tgt) {
+		t.Errorf("sort by name: expected %v got %v", tgt, order)
+	}
+
+	for _, tc := range []struct {
+		filter string
+		tgt    []bool
+	}{
+		{`Age > 10`, []bool{true, false, true}},
+		{`Addr.City == "Rome" && Age < 20`, []bool{false, false, true}},
+		{`!(Name == "alice") || Age*2 == 8`, []bool{true, true, true}},
+	} {
+		f, err := compileTableFilter(tc.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tbl.rows {
+			ok, err := f.match(tbl, i)
+			if err != nil || ok != tc.tgt[i] {
+				t.Errorf("%q row %d: expected %v got %v %v", tc.filter, i, tc.tgt[i], ok, err)
+			}
+		}
+	}
+
+	f, _ := compileTableFilter(`Age == "x"`)
+	if _, err := f.match(tbl, 0); err == nil {
+		t.Errorf("expected error comparing number with string")
+	}
+}
+
+func TestImageScale(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 10, 14, 12))
+	img.Set(10, 10, color.RGBA{255, 0, 0, 255})
+	img.Set(13, 11, color.RGBA{0, 0, 255, 255})
+
+	s := scaleImage(img, 2)
+	if sz := s.Bounds().Size(); sz != (image.Point{8, 4}) {
+		t.Fatalf("wrong scaled size %v", sz)
+	}
+	for _, tc := range []struct {
+		x, y int
+		c    color.RGBA
+	}{
+		{0, 0, color.RGBA{255, 0, 0, 255}},
+		{1, 1, color.RGBA{255, 0, 0, 255}},
+		{2, 0, color.RGBA{}},
+		{7, 3, color.RGBA{0, 0, 255, 255}},
+	} {
+		if c := s.RGBAAt(tc.x, tc.y); c != tc.c {
+			t.Errorf("pixel %d,%d: expected %v got %v", tc.x, tc.y, tc.c, c)
+		}
+	}
+
+	if sz := scaleImage(img, -4).Bounds().Size(); sz != (image.Point{1, 1}) {
+		t.Errorf("wrong scaled size %v", sz)
+	}
+
+	if out := formatImagePixel(img, 13, 11); out != "(13, 11) color.RGBA{0 0 255 255}" {
+		t.Errorf("wrong pixel description %q", out)
+	}
+}
+
+func TestCheckImageLayout(t *testing.T) {
+	r := image.Rect(10, 10, 14, 12)
+	for _, tc := range []struct {
+		typ       string
+		r         image.Rectangle
+		stride    int
+		cstride   int
+		subsample image.YCbCrSubsampleRatio
+		lens      []int
+		ok        bool
+	}{
+		{"*image.RGBA", r, 16, 0, 0, []int{32}, true},
+		{"*image.RGBA", r, 16, 0, 0, []int{31}, false},
+		{"*image.RGBA", r, 0, 0, 0, []int{32}, false},
+		{"*image.RGBA", r, -16, 0, 0, []int{32}, false},
+		{"*image.Gray", r, 4, 0, 0, []int{8}, true},
+		{"*image.Gray", r, 100, 0, 0, []int{8}, false},
+		{"*image.Paletted", image.Rect(0, 0, 1<<20, 1<<20), 1 << 20, 0, 0, []int{1 << 20}, false},
+		{"*image.RGBA", image.Rect(0, 0, 0, 0), 4, 0, 0, []int{0}, true},
+		{"*image.YCbCr", r, 4, 2, image.YCbCrSubsampleRatio420, []int{8, 2, 2}, true},
+		{"*image.YCbCr", r, 4, 2, image.YCbCrSubsampleRatio420, []int{8, 2, 1}, false},
+		{"*image.YCbCr", r, 4, 4, image.YCbCrSubsampleRatio444, []int{8, 4, 8}, false},
+		{"*image.YCbCr", r, 4, 2, image.YCbCrSubsampleRatio(42), []int{8, 8, 8}, false},
+		{"*image.YCbCr", r, 4, 0, image.YCbCrSubsampleRatio444, []int{8, 8, 8}, false},
+	} {
+		err := checkImageLayout(tc.typ, tc.r, tc.stride, tc.cstride, tc.subsample, tc.lens)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s %v stride=%d cstride=%d subsample=%d lens=%v: unexpected result %v", tc.typ, tc.r, tc.stride, tc.cstride, tc.subsample, tc.lens, err)
+		}
+	}
+}
+
+func TestPlotView(t *testing.T) {
+	s := computePlotStats([]float64{2, 4, 4, 4, 5, 5, 7, 9})
+	if s.min != 2 || s.max != 9 || s.mean != 5 || s.stddev != 2 {
+		t.Errorf("wrong stats %#v", s)
+	}
+
+	var pv plotView
+	var children []*Variable
+	for i := 0; i < 100; i++ {
+		children = append(children, wrapApiVariableSimple(&api.Variable{Kind: reflect.Float64, Type: "float64", Value: fmt.Sprintf("%d.5", i)}))
+	}
+	pv.setValues(children)
+	if pv.lo != 0 || pv.hi != 100 {
+		t.Errorf("wrong initial range %g %g", pv.lo, pv.hi)
+	}
+	pv.zoom(50, 0.5)
+	if pv.lo != 25 || pv.hi != 75 {
+		t.Errorf("wrong range after zoom %g %g", pv.lo, pv.hi)
+	}
+	pv.pan(-40)
+	if pv.lo != 0 || pv.hi != 50 {
+		t.Errorf("wrong range after pan %g %g", pv.lo, pv.hi)
+	}
+	if start, end, min, max := pv.visibleRange(); start != 0 || end != 50 || min != 0.5 || max != 49.5 {
+		t.Errorf("wrong visible range %d %d %g %g", start, end, min, max)
+	}
+
+	s = computePlotStats([]float64{2, math.NaN(), 4, 4, 4, math.Inf(1), 5, 5, 7, math.Inf(-1), 9})
+	if s.min != 2 || s.max != 9 || s.mean != 5 || s.stddev != 2 {
+		t.Errorf("wrong stats with non-finite values %#v", s)
+	}
+	pv.values[3], pv.values[10] = math.NaN(), math.Inf(1)
+	pv.values[0] = math.Inf(-1)
+	if _, _, min, max := pv.visibleRange(); min != 1.5 || max != 49.5 {
+		t.Errorf("wrong visible range with non-finite values %g %g", min, max)
+	}
+
+	for _, tc := range []struct {
+		typ  string
+		kind reflect.Kind
+		tgt  reflect.Kind
+	}{
+		{"[]float64", reflect.Slice, reflect.Float64},
+		{"[16]int", reflect.Array, reflect.Int},
+		{"[]string", reflect.Slice, reflect.Invalid},
+		{"float64", reflect.Float64, reflect.Invalid},
+	} {
+		if k := numericArrayKind(wrapApiVariableSimple(&api.Variable{Type: tc.typ, Kind: tc.kind})); k != tc.tgt {
+			t.Errorf("%s: expected %v got %v", tc.typ, tc.tgt, k)
+		}
+	}
+}
+
+func TestDecodeBytes(t *testing.T) {
+	var dump func(nodes []*dataTreeNode, indent string) string
+	dump = func(nodes []*dataTreeNode, indent string) string {
+		var buf strings.Builder
+		for _, n := range nodes {
+			fmt.Fprintf(&buf, "%s%s: %s\n", indent, n.Key, n.Value)
+			buf.WriteString(dump(n.Children, indent+"\t"))
+		}
+		return buf.String()
+	}
+
+	n, err := decodeJSONTree([]byte(`{"b": 1, "a": [true, null, "x"]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, tgt := dump([]*dataTreeNode{n}, ""), ": {2 fields}\n\tb: 1\n\ta: [3 elements]\n\t\t[0]: true\n\t\t[1]: null\n\t\t[2]: \"x\"\n"; out != tgt {
+		t.Errorf("JSON: expected:\n%s\ngot:\n%s", tgt, out)
+	}
+	if _, err := decodeJSONTree([]byte(`{"a": 1} x`)); err == nil {
+		t.Errorf("expected error for trailing data")
+	}
+
+	// field 1 varint 150, field 2 string "testing", field 3 message {1: 1}, field 4 varint -1
+	msg := []byte{0x08, 0x96, 0x01, 0x12, 0x07, 't', 'e', 's', 't', 'i', 'n', 'g', 0x1a, 0x02, 0x08, 0x01, 0x20, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	nodes, err := decodeProtobuf(msg, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out, tgt := dump(nodes, ""), "1: 150\n2: \"testing\"\n3: message (2 bytes)\n\t1: 1\n4: 18446744073709551615 (int64 -1)\n"; out != tgt {
+		t.Errorf("protobuf: expected:\n%s\ngot:\n%s", tgt, out)
+	}
+	if _, err := decodeProtobuf([]byte{0x12, 0x10, 'a'}, 0); err == nil {
+		t.Errorf("expected error for truncated message")
+	}
+
+	if out, err := decodeHex([]byte("0x48 65 6c6c6f")); err != nil || string(out) != "Hello" {
+		t.Errorf("hex: got %q %v", out, err)
+	}
+	for _, in := range []string{"SGVsbG8/", "SGVsbG8_", "SGVsbG8"} {
+		if out, err := decodeBase64([]byte(in)); err != nil || !strings.HasPrefix(string(out), "Hello") {
+			t.Errorf("base64 %q: got %q %v", in, out, err)
+		}
+	}
+}
+
+func TestStarlarkHooks(t *testing.T) {
+	env := starbind.New(starlarkContext{})
+	var buf bytes.Buffer
+	_, err := env.Execute(&buf, "hooks.star", "def on_exit(status):\n\tprint('exited', status)\n\ndef on_stop(state):\n\tprint('stop')\n", "", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !env.HasHook(starbind.OnExitHook) || env.HasHook(starbind.OnRestartHook) {
+		t.Errorf("wrong hooks registered")
+	}
+	if err := env.CallHook(&buf, starbind.OnExitHook, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.CallHook(&buf, starbind.OnRestartHook); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.CallHook(&buf, starbind.OnStopHook); err == nil {
+		t.Errorf("expected error calling hook with the wrong number of arguments")
+	}
+	if out := buf.String(); out != "exited 3\n" {
+		t.Errorf("wrong output %q", out)
+	}
+
+	if status, ok := parseExitStatus(errors.New("Process 1234 has exited with status 2")); !ok || status != 2 {
+		t.Errorf("wrong exit status %d %v", status, ok)
+	}
+	if _, ok := parseExitStatus(errors.New("could not attach")); ok {
+		t.Errorf("unexpected exit status")
+	}
+}
+
+type panelTestContext struct {
+	starlarkContext
+	render func(out io.Writer) (*starbind.PanelContent, error)
+}
+
+func (ctx *panelTestContext) RegisterPanel(name string, render func(out io.Writer) (*starbind.PanelContent, error)) error {
+	ctx.render = render
+	return nil
+}
+
+func TestStarlarkPanel(t *testing.T) {
+	ctx := &panelTestContext{}
+	env := starbind.New(ctx)
+	var buf bytes.Buffer
+	const src = `
+def render():
+	return {
+		"text": "pool state",
+		"columns": ["id", "busy"],
+		"rows": [[1, True], [2, "no"]],
+		"tree": [{"name": "conns", "value": 2, "children": ["a", "b"]}],
+	}
+
+panel("pool", render)
+`
+	if _, err := env.Execute(&buf, "panel.star", src, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ctx.render == nil {
+		t.Fatal("panel not registered")
+	}
+	content, err := ctx.render(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree := panelNodesToDataTree(content.Tree)
+	if content.Text != "pool state" || !reflect.DeepEqual(content.Columns, []string{"id", "busy"}) || !reflect.DeepEqual(content.Rows, [][]string{{"1", "True"}, {"2", "no"}}) {
+		t.Errorf("wrong content %#v", content)
+	}
+	if len(tree) != 1 || tree[0].Key != "conns" || tree[0].Value != "2" || len(tree[0].Children) != 2 || tree[0].Children[1].Value != "b" {
+		t.Errorf("wrong tree %#v", tree)
+	}
+
+	if _, err := env.Execute(&buf, "panel.star", "panel(\"bad\", lambda: {\"rows\": 1})\n", "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ctx.render(&buf); err == nil {
+		t.Errorf("expected error for malformed rows")
+	}
+}
+
+func TestScriptFormatters(t *testing.T) {
+	defer func() {
+		scriptFormatters.list = nil
+		clearScriptFormatterCache()
+	}()
+	env := starbind.New(starlarkContext{})
+	var buf bytes.Buffer
+	const src = `
+register_formatter("main.Celsius", lambda x: "%d°C" % x)
+register_formatter("main\\..*Request", lambda x: "request")
+register_formatter("main.Broken", lambda x: x.Missing)
+`
+	if _, err := env.Execute(&buf, "fmt.star", src, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	temp := &api.Variable{Type: "main.Celsius", Kind: reflect.Int, Addr: 0x1000, Value: "20"}
+	req := &api.Variable{Type: "main.HTTPRequest", Kind: reflect.Struct}
+	broken := &api.Variable{Type: "main.Broken", Kind: reflect.Struct, Len: 1, Children: []api.Variable{{Name: "A", Type: "int", Kind: reflect.Int, Value: "1"}}}
+
+	// formatters are only called by loadVariable and loadVariables
+	if v := wrapApiVariable(temp, "temp", "temp", true, 0); v.Value != "20" {
+		t.Errorf("formatter applied by wrapApiVariable %q", v.Value)
+	}
+	if v := loadVariable(temp, "temp", "temp"); v.Value != "20°C" {
+		t.Errorf("wrong value for main.Celsius %q", v.Value)
+	}
+	v := wrapApiVariable(temp, "temp", "temp", false, 0)
+	applyScriptFormatters(v)
+	if v.Value != "20" {
+		t.Errorf("formatter applied when custom formatters are disabled %q", v.Value)
+	}
+	if v := loadVariable(req, "req", "req"); v.Value != "request" {
+		t.Errorf("wrong value for main.HTTPRequest %q", v.Value)
+	}
+	if v := loadVariable(broken, "b", "b"); !strings.HasPrefix(v.Value, "formatter error (main.Broken)") {
+		t.Errorf("wrong value for main.Broken %q", v.Value)
+	}
+	// nested values are formatted when printed
+	weather := &api.Variable{Name: "w", Type: "main.Weather", Kind: reflect.Struct, Len: 1, Children: []api.Variable{*temp}}
+	weather.Children[0].Name = "Temp"
+	if s := prettyprint.Multiline(loadVariable(weather, "w", "w").formattedApiVariable(), ""); s != "main.Weather {Temp: 20°C}" {
+		t.Errorf("wrong printed value for main.Weather %q", s)
+	}
+	if f := findScriptFormatter("main.HTTPRequestX"); f != nil {
+		t.Errorf("unexpected formatter %q", f.pattern)
+	}
+
+	// formatted values are cached until the target stops
+	temp.Value = "30"
+	if v := loadVariable(temp, "temp", "temp"); v.Value != "20°C" {
+		t.Errorf("wrong cached value for main.Celsius %q", v.Value)
+	}
+	clearScriptFormatterCache()
+	if v := loadVariable(temp, "temp", "temp"); v.Value != "30°C" {
+		t.Errorf("wrong value for main.Celsius after clearing the cache %q", v.Value)
+	}
+}
+
+func TestStarlarkCompletion(t *testing.T) {
+	env := starbind.New(starlarkContext{})
+	var buf bytes.Buffer
+	if _, err := env.Execute(&buf, "compl.star", "Cfg = {\"a\": 1}\n", "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		word string
+		tgt  []string
+	}{
+		{"read_", []string{"read_file", "read_memory"}},
+		{"le", []string{"len"}},
+		{"Cf", []string{"Cfg"}},
+		{"Cfg.ke", []string{"Cfg.keys"}},
+		{"nonexistent.x", nil},
+	} {
+		if out := env.Complete(tc.word); !reflect.DeepEqual(out, tc.tgt) {
+			t.Errorf("completing %q: got %q expected %q", tc.word, out, tc.tgt)
+		}
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "gdlv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return dir
+}
+
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestStarlarkHistory(t *testing.T) {
+	home := tempDir(t)
+	if err := os.Mkdir(filepath.Join(home, ".config"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "HOME", home)
+	appendStarlarkHistory("x = 1")
+	appendStarlarkHistory("def f():\n\treturn 1")
+	if h := loadStarlarkHistory(); !reflect.DeepEqual(h, []string{"", "x = 1", "def f():\n\treturn 1"}) {
+		t.Errorf("wrong history %q", h)
+	}
+}
+
+func TestStarlarkREPLBlock(t *testing.T) {
+	env := starbind.New(starlarkContext{})
+	lines := make(chan string)
+	prompts := make(chan string)
+	var buf bytes.Buffer
+	done := make(chan error)
+	go func() {
+		done <- env.REPL(&buf, lines, prompts)
+	}()
+	go func() {
+		for range prompts {
+		}
+	}()
+	lines <- "def f(x):\n\tif x:\n\t\treturn 1\n\treturn 2"
+	lines <- "print(f(True), f(False))"
+	lines <- "exit"
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); out != "1 2\n\n" {
+		t.Errorf("wrong output %q", out)
+	}
+}
+
+func TestStarlarkLoad(t *testing.T) {
+	dir := tempDir(t)
+	if err := ioutil.WriteFile(filepath.Join(dir, "mylib.star"), []byte("def double(x):\n\treturn 2*x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	setenv(t, "GDLV_STARLARK_PATH", dir)
+	env := starbind.New(starlarkContext{})
+	var buf bytes.Buffer
+	src := "load(\"mylib.star\", \"double\")\nload(\"table.star\", \"format_table\")\nprint(format_table([\"a\", \"b\"], [[double(1), \"x\"], [double(50), \"y\"]]))\n"
+	if _, err := env.Execute(&buf, "load.star", src, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); out != "a    b\n2    x\n100  y\n" {
+		t.Errorf("wrong output %q", out)
+	}
+	if _, err := env.Execute(&buf, "load.star", "load(\"nonexistent.star\", \"f\")\n", "", nil, nil); err == nil {
+		t.Errorf("expected error loading nonexistent module")
+	}
+
+	// modules run on the thread of the script that loads them and can be
+	// interrupted
+	if err := ioutil.WriteFile(filepath.Join(dir, "loop.star"), []byte("for i in range(1000000):\n\tfor j in range(1000000):\n\t\tpass\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() {
+		_, err := env.Execute(&buf, "load.star", "load(\"loop.star\", \"i\")\n", "", nil, nil)
+		done <- err
+	}()
+	for {
+		select {
+		case err := <-done:
+			if err == nil {
+				t.Errorf("expected error interrupting module")
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+			env.Cancel()
+		}
+	}
+}
+
+func TestStarlarkMemoryArgs(t *testing.T) {
+	env := starbind.New(starlarkContext{})
+	var buf bytes.Buffer
+	for _, src := range []string{
+		"read_memory(\"x\", 1)",
+		"read_memory(0x1000, -1)",
+		"read_memory(0x1000)",
+		"read_memory(0x1000, 1 << 40)",
+		"type_info(1)",
+		"sizeof()",
+	} {
+		if _, err := env.Execute(&buf, "<expr>", src, "<expr>", nil, nil); err == nil {
+			t.Errorf("expected error evaluating %s", src)
+		}
+	}
+}
+
+// fakeRPCServer answers the Eval requests of a rpc2.RPCClient using eval.
+type fakeRPCServer struct {
+	eval func(expr string) (*api.Variable, error)
+}
+
+func (s *fakeRPCServer) SetApiVersion(args api.SetAPIVersionIn, out *api.SetAPIVersionOut) error {
+	return nil
+}
+
+func (s *fakeRPCServer) Eval(args rpc2.EvalIn, out *rpc2.EvalOut) error {
+	v, err := s.eval(args.Expr)
+	out.Variable = v
+	return err
+}
+
+func newFakeClient(t *testing.T, srv *fakeRPCServer) *rpc2.RPCClient {
+	rpcsrv := rpc.NewServer()
+	if err := rpcsrv.RegisterName("RPCServer", srv); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go rpcsrv.ServeCodec(jsonrpc.NewServerCodec(conn))
+		}
+	}()
+	c, err := rpc2.NewClient(l.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Disconnect(false) })
+	return c
+}
+
+type fakeClientContext struct {
+	starlarkContext
+	client *rpc2.RPCClient
+}
+
+func (ctx *fakeClientContext) Client() *rpc2.RPCClient {
+	return ctx.client
+}
+
+func TestStarlarkTypeInfo(t *testing.T) {
+	// main.T is struct { A int; B uint8; C main.hidden } where main.hidden
+	// can not be found by name
+	sizes := map[string]uint64{"main.T": 32, "int": 8, "uint8": 1}
+	srv := &fakeRPCServer{eval: func(expr string) (*api.Variable, error) {
+		if expr == `*(*"main.T")(0x1000)` {
+			return &api.Variable{Type: "main.T", Kind: reflect.Struct, Addr: 0x1000, Children: []api.Variable{
+				{Name: "A", Type: "int", Kind: reflect.Int, Addr: 0x1000},
+				{Name: "B", Type: "uint8", Kind: reflect.Uint8, Addr: 0x1008},
+				{Name: "C", Type: "main.hidden", Kind: reflect.Struct, Addr: 0x1010},
+			}}, nil
+		}
+		for name, size := range sizes {
+			if expr == fmt.Sprintf("*(*%q)(0x1000)", "[2]"+name) {
+				return &api.Variable{Type: "[2]" + name, Kind: reflect.Array, Addr: 0x1000, Len: 2, Children: []api.Variable{{Addr: 0x1000}, {Addr: uintptr(0x1000 + size)}}}, nil
+			}
+		}
+		return nil, fmt.Errorf("could not find symbol value for %s", expr)
+	}}
+	env := starbind.New(&fakeClientContext{client: newFakeClient(t, srv)})
+	var buf bytes.Buffer
+	const src = `
+ti = type_info("main.T")
+print(ti.Name, ti.Kind, ti.Size)
+for f in ti.Fields:
+	print(f.Name, f.Type, f.Offset, f.Size)
+`
+	if _, err := env.Execute(&buf, "ti.star", src, "", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	const tgt = "main.T struct 32\nA int 0 8\nB uint8 8 1\nC main.hidden 16 16\n"
+	if out := buf.String(); out != tgt {
+		t.Errorf("wrong output %q expected %q", out, tgt)
+	}
+}
+
+func TestStarlarkRunTests(t *testing.T) {
+	path := filepath.Join(tempDir(t), "x_test.star")
+	src := "def test_pass():\n\tpass\n\ndef test_fail():\n\tfail(\"boom\")\n\ndef helper():\n\tfail(\"not a test\")\n"
+	if err := ioutil.WriteFile(path, []byte(src), 0600); err != nil {
+		t.Fatal(err)
+	}
+	env := starbind.New(starlarkContext{})
+	var buf bytes.Buffer
+	nsetup := 0
+	failed, err := env.RunTests(&buf, path, func() error {
+		nsetup++
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if failed != 1 || nsetup != 2 {
+		t.Errorf("wrong result failed=%d setup=%d", failed, nsetup)
+	}
+	if !strings.Contains(out, "--- PASS: test_pass") || !strings.Contains(out, "--- FAIL: test_fail") || !strings.Contains(out, "boom") || strings.Contains(out, "helper") {
+		t.Errorf("wrong output %q", out)
+	}
+	if strings.Index(out, "test_pass") > strings.Index(out, "test_fail") {
+		t.Errorf("tests not run in order %q", out)
+	}
+
+	buf.Reset()
+	failed, _ = env.RunTests(&buf, path, func() error { return errors.New("restart failed") })
+	if failed != 2 || !strings.Contains(buf.String(), "restart failed") {
+		t.Errorf("wrong output for failing setup %q", buf.String())
+	}
+}
+
+func TestExprCondValue(t *testing.T) {
+	c := func(cond string, v *api.Variable, tgt bool, fails bool) {
+		out, err := exprCondValue(v)
+		if (err != nil) != fails {
+			t.Errorf("%s: unexpected error %v", cond, err)
+		}
+		if out != tgt {
+			t.Errorf("%s: expected %v got %v", cond, tgt, out)
+		}
+	}
+	c("x > 1", &api.Variable{Kind: reflect.Bool, Value: "true"}, true, false)
+	c("x > 1", &api.Variable{Kind: reflect.Bool, Value: "false"}, false, false)
+	c("s", &api.Variable{Kind: reflect.String, Value: "True"}, false, true)
+	c("$x>1", convertStarlarkToVariable("$x>1", starlark.True), true, false)
+	c("$x>1", convertStarlarkToVariable("$x>1", starlark.False), false, false)
+	c("$x", convertStarlarkToVariable("$x", starlark.String("True")), false, true)
+	c("$x", convertStarlarkToVariable("$x", starlark.MakeInt(1)), false, true)
+	c("y", &api.Variable{Unreadable: "could not find symbol value for y"}, false, true)
+}
+
+func TestSplitDisplayCond(t *testing.T) {
+	for _, tc := range []struct{ in, cond, expr string }{
+		{"x > 0 y.z", "x > 0", "y.z"},
+		{"req != nil req.URL.Path", "req != nil", "req.URL.Path"},
+		{"@f/handler/ ok buf[:n]", "@f/handler/ ok", "buf[:n]"},
+		{"$cur_scope().Frame>0 $ len(x) + 1", "$cur_scope().Frame>0", "$ len(x) + 1"},
+	} {
+		cond, expr, err := splitDisplayCond(tc.in)
+		if err != nil || cond != tc.cond || expr != tc.expr {
+			t.Errorf("%q: expected %q %q got %q %q %v", tc.in, tc.cond, tc.expr, cond, expr, err)
+		}
+	}
+	if _, _, err := splitDisplayCond("x >"); err == nil {
+		t.Errorf("expected error splitting %q", "x >")
+	}
+}
+
+func TestSplitRegisterAssignment(t *testing.T) {
+	for _, tc := range []struct {
+		in         string
+		reg, value string
+		ok         bool
+	}{
+		{"$rax = 1", "$rax", "1", true},
+		{"  $rip=0x401000 ", "$rip", "0x401000", true},
+		{"$xmm0 = $xmm1", "$xmm0", "$xmm1", true},
+		{"$rax==1", "", "", false},
+		{"$rax != 1", "", "", false},
+		{"$rax = ", "", "", false},
+		{"$ = 1", "", "", false},
+		{"$rax", "", "", false},
+		{"x = 1", "", "", false},
+	} {
+		reg, value, ok := splitRegisterAssignment(tc.in)
+		if reg != tc.reg || value != tc.value || ok != tc.ok {
+			t.Errorf("%q: expected %q %q %v got %q %q %v", tc.in, tc.reg, tc.value, tc.ok, reg, value, ok)
+		}
+	}
+}
+
+func TestHeadlessWindow(t *testing.T) {
+	defer func(old masterWindow) { wnd = old }(wnd)
+	wnd = &headlessWindow{}
+	if !headless() {
+		t.Fatal("headless window not detected")
+	}
+	wnd.Lock()
+	wnd.Changed()
+	wnd.PopupOpen("x", 0, rect.Rect{}, true, nil)
+	if len(wnd.Input().Keyboard.Keys) != 0 {
+		t.Errorf("unexpected input")
+	}
+	wnd.Unlock()
+	wnd.Close()
+	if err := layoutCommand(ioutil.Discard, "save x"); err == nil {
+		t.Errorf("expected error saving layout without a window")
+	}
+}
+
+func TestScopeVariableExpressions(t *testing.T) {
+	v := &api.Variable{Name: "s", Kind: reflect.Slice, Children: []api.Variable{{Kind: reflect.Int, Value: "1"}}}
+	vs := wrapApiVariables([]api.Variable{*v}, 0, 0, "", false, 0)
+	scopeVariableExpressions(vs, fmt.Sprintf("@g%df%dd%d ", 1, 2, 3))
+	if vs[0].Expression != "@g1f2d3 s" || vs[0].Children[0].Expression != "@g1f2d3 s[0]" {
+		t.Fatalf("wrong expressions %q %q", vs[0].Expression, vs[0].Children[0].Expression)
+	}
+	se := ParseScopedExpr(vs[0].Children[0].Expression)
+	if se.Kind != NormalScopeExpr || se.Gid != 1 || se.Fid != 2 || se.DeferredCall != 3 || se.EvalExpr != "s[0]" {
+		t.Fatalf("wrong scope %#v", se)
+	}
+}
+
+func TestScriptPanelCode(t *testing.T) {
+	used := map[byte]string{}
+	a, ok := scriptPanelCode("goroutine-stats", used)
+	if !ok || strings.IndexByte(scriptPanelCodes, a) < 0 {
+		t.Fatalf("invalid code %q", a)
+	}
+	// the code does not depend on the panels defined before
+	for i := range scriptPanelCodes {
+		if c := scriptPanelCodes[i]; c != a {
+			if a2, _ := scriptPanelCode("goroutine-stats", map[byte]string{c: "other"}); a2 != a {
+				t.Errorf("code changed from %q to %q", a, a2)
+			}
+		}
+	}
+	used[a] = "goroutine-stats"
+	if b, ok := scriptPanelCode("goroutine-stats", used); !ok || b == a {
+		t.Errorf("used code %q returned", b)
+	}
+	for i := range scriptPanelCodes {
+		used[scriptPanelCodes[i]] = "x"
+	}
+	if _, ok := scriptPanelCode("other", used); ok {
+		t.Errorf("code returned when all codes are used")
+	}
+}
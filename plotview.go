@@ -0,0 +1,252 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/rect"
+	nstyle "github.com/aarzilli/nucular/style"
+	"golang.org/x/mobile/event/mouse"
+)
+
+// numericArrayKind returns the kind of the elements of v if v is a slice
+// or array of numbers, reflect.Invalid otherwise.
+func numericArrayKind(v *Variable) reflect.Kind {
+	if v.Kind != reflect.Slice && v.Kind != reflect.Array {
+		return reflect.Invalid
+	}
+	typ := v.Type
+	if i := strings.Index(typ, "]"); i >= 0 && strings.HasPrefix(typ, "[") {
+		typ = typ[i+1:]
+	}
+	switch typ {
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "uintptr":
+		return reflect.Int
+	case "float32", "float64":
+		return reflect.Float64
+	}
+	return reflect.Invalid
+}
+
+// plotView draws a numeric array as a line or scatter plot.
+type plotView struct {
+	values  []float64
+	stats   plotStats
+	scatter bool
+
+	lo, hi float64 // visible range of indexes
+}
+
+type plotStats struct {
+	min, max, mean, stddev float64
+}
+
+// isFinite returns true if x is neither NaN nor an infinity, only finite
+// values are plotted.
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+func computePlotStats(values []float64) plotStats {
+	s := plotStats{min: math.Inf(1), max: math.Inf(-1)}
+	n := 0
+	for _, x := range values {
+		if !isFinite(x) {
+			continue
+		}
+		s.min = math.Min(s.min, x)
+		s.max = math.Max(s.max, x)
+		s.mean += x
+		n++
+	}
+	if n == 0 {
+		return plotStats{}
+	}
+	s.mean /= float64(n)
+	for _, x := range values {
+		if isFinite(x) {
+			s.stddev += (x - s.mean) * (x - s.mean)
+		}
+	}
+	s.stddev = math.Sqrt(s.stddev / float64(n))
+	return s
+}
+
+func (pv *plotView) setValues(children []*Variable) {
+	pv.values = pv.values[:0]
+	for _, child := range children {
+		x, _ := strconv.ParseFloat(child.Variable.Value, 64)
+		pv.values = append(pv.values, x)
+	}
+	pv.stats = computePlotStats(pv.values)
+	if pv.hi <= pv.lo || pv.hi > float64(len(pv.values)) {
+		pv.resetZoom()
+	}
+}
+
+func (pv *plotView) resetZoom() {
+	pv.lo, pv.hi = 0, float64(len(pv.values))
+}
+
+// zoom scales the visible range by factor keeping the index at center
+// fixed.
+func (pv *plotView) zoom(center, factor float64) {
+	lo := center - (center-pv.lo)*factor
+	hi := center + (pv.hi-center)*factor
+	if hi-lo < 2 {
+		return
+	}
+	pv.lo, pv.hi = lo, hi
+	pv.clamp()
+}
+
+func (pv *plotView) pan(delta float64) {
+	pv.lo += delta
+	pv.hi += delta
+	pv.clamp()
+}
+
+func (pv *plotView) clamp() {
+	n := float64(len(pv.values))
+	if pv.hi-pv.lo > n {
+		pv.resetZoom()
+		return
+	}
+	if pv.lo < 0 {
+		pv.lo, pv.hi = 0, pv.hi-pv.lo
+	}
+	if pv.hi > n {
+		pv.lo, pv.hi = n-(pv.hi-pv.lo), n
+	}
+}
+
+// visibleRange returns the range of values that are visible and their
+// minimum and maximum.
+func (pv *plotView) visibleRange() (start, end int, min, max float64) {
+	start, end = int(math.Floor(pv.lo)), int(math.Ceil(pv.hi))
+	if start < 0 {
+		start = 0
+	}
+	if end > len(pv.values) {
+		end = len(pv.values)
+	}
+	min, max = math.Inf(1), math.Inf(-1)
+	for _, x := range pv.values[start:end] {
+		if isFinite(x) {
+			min = math.Min(min, x)
+			max = math.Max(max, x)
+		}
+	}
+	if min > max {
+		// no finite values are visible
+		min, max = 0, 0
+	}
+	if min == max {
+		min, max = min-1, max+1
+	}
+	return
+}
+
+func (pv *plotView) update(w *nucular.Window) {
+	w.Row(20).Static(100, 100, 100)
+	if w.OptionText("Line", !pv.scatter) {
+		pv.scatter = false
+	}
+	if w.OptionText("Scatter", pv.scatter) {
+		pv.scatter = true
+	}
+	if w.ButtonText("Reset zoom") {
+		pv.resetZoom()
+	}
+
+	w.Row(20).Dynamic(1)
+	w.Label(fmt.Sprintf("n=%d min=%g max=%g mean=%g stddev=%g", len(pv.values), pv.stats.min, pv.stats.max, pv.stats.mean, pv.stats.stddev), "LC")
+
+	w.Row(0).Dynamic(1)
+	bounds, out := w.Custom(nstyle.WidgetStateInactive)
+	if out == nil || len(pv.values) == 0 {
+		return
+	}
+
+	style := w.Master().Style()
+	axisColor := style.Text.Color
+	darken(&axisColor)
+	fh := nucular.FontHeight(style.Font)
+
+	start, end, min, max := pv.visibleRange()
+	maxlbl, minlbl := fmt.Sprintf("%g", max), fmt.Sprintf("%g", min)
+	margin := nucular.FontWidth(style.Font, maxlbl)
+	if w := nucular.FontWidth(style.Font, minlbl); w > margin {
+		margin = w
+	}
+	margin += spaceWidth
+	area := rect.Rect{X: bounds.X + margin, Y: bounds.Y + fh/2, W: bounds.W - margin - spaceWidth, H: bounds.H - 2*fh}
+	if area.W <= 0 || area.H <= 0 {
+		return
+	}
+
+	out.DrawText(rect.Rect{X: bounds.X, Y: area.Y - fh/2, W: margin, H: fh}, maxlbl, style.Font, axisColor)
+	out.DrawText(rect.Rect{X: bounds.X, Y: area.Y + area.H - fh/2, W: margin, H: fh}, minlbl, style.Font, axisColor)
+	out.DrawText(rect.Rect{X: area.X, Y: area.Y + area.H + fh/2, W: area.W, H: fh}, strconv.Itoa(start), style.Font, axisColor)
+	endlbl := strconv.Itoa(end - 1)
+	out.DrawText(rect.Rect{X: area.X + area.W - nucular.FontWidth(style.Font, endlbl), Y: area.Y + area.H + fh/2, W: area.W, H: fh}, endlbl, style.Font, axisColor)
+	out.StrokeLine(image.Point{area.X, area.Y}, image.Point{area.X, area.Y + area.H}, 1, axisColor)
+	out.StrokeLine(image.Point{area.X, area.Y + area.H}, image.Point{area.X + area.W, area.Y + area.H}, 1, axisColor)
+
+	toScreen := func(i int, x float64) image.Point {
+		sx := area.X + int((float64(i)+0.5-pv.lo)/(pv.hi-pv.lo)*float64(area.W))
+		sy := area.Y + area.H - int((x-min)/(max-min)*float64(area.H))
+		return image.Point{sx, sy}
+	}
+
+	savedClip := out.Clip
+	out.PushScissor(area)
+	defer out.PushScissor(savedClip)
+	var prev image.Point
+	havePrev := false
+	for i := start; i < end; i++ {
+		if !isFinite(pv.values[i]) {
+			// lines are interrupted by non-finite values
+			havePrev = false
+			continue
+		}
+		p := toScreen(i, pv.values[i])
+		if pv.scatter {
+			out.FillCircle(rect.Rect{X: p.X - 2, Y: p.Y - 2, W: 5, H: 5}, linkColor)
+		} else if havePrev {
+			out.StrokeLine(prev, p, 1, linkColor)
+		}
+		prev, havePrev = p, true
+	}
+
+	in := w.Input()
+	if !in.Mouse.HoveringRect(area) {
+		return
+	}
+	idx := func(x int) float64 {
+		return pv.lo + float64(x-area.X)/float64(area.W)*(pv.hi-pv.lo)
+	}
+	if in.Mouse.ScrollDelta != 0 {
+		factor := 0.8
+		if in.Mouse.ScrollDelta < 0 {
+			factor = 1 / factor
+		}
+		pv.zoom(idx(in.Mouse.Pos.X), factor)
+		in.Mouse.ScrollDelta = 0
+	}
+	if in.Mouse.Down(mouse.ButtonLeft) && in.Mouse.Delta.X != 0 {
+		pv.pan(-float64(in.Mouse.Delta.X) / float64(area.W) * (pv.hi - pv.lo))
+	}
+	if i := int(idx(in.Mouse.Pos.X)); i >= start && i < end {
+		if isFinite(pv.values[i]) {
+			p := toScreen(i, pv.values[i])
+			out.FillCircle(rect.Rect{X: p.X - 4, Y: p.Y - 4, W: 9, H: 9}, linkHoverColor)
+		}
+		w.Tooltip(fmt.Sprintf("[%d] %g", i, pv.values[i]))
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image/color"
+	"math"
+	"strings"
+)
+
+var changedRegisterColor = color.RGBA{0xff, 0x55, 0x55, 0xff}
+
+const (
+	vectorInt8 = iota
+	vectorInt16
+	vectorInt32
+	vectorInt64
+	vectorFloat32
+	vectorFloat64
+)
+
+var vectorFormats = []string{"int8", "int16", "int32", "int64", "float32", "float64"}
+
+// splitRegisterValue splits the value of a register, as returned by the
+// backend, into its hexadecimal value and the description that follows it.
+func splitRegisterValue(v string) (value, descr string) {
+	v = strings.TrimSpace(v)
+	if idx := strings.IndexAny(v, " \t"); idx >= 0 {
+		return v[:idx], strings.TrimSpace(v[idx+1:])
+	}
+	return v, ""
+}
+
+// registerBytes parses the hexadecimal value of a register and returns
+// its contents in little endian order.
+func registerBytes(v string) ([]byte, bool) {
+	v, _ = splitRegisterValue(v)
+	if !strings.HasPrefix(v, "0x") {
+		return nil, false
+	}
+	v = v[2:]
+	if len(v)%2 != 0 {
+		v = "0" + v
+	}
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return nil, false
+	}
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return b, true
+}
+
+func isFlagsRegister(name string) bool {
+	return strings.EqualFold(name, "rflags") || strings.EqualFold(name, "eflags")
+}
+
+var flagsBits = []struct {
+	bit  uint
+	name string
+}{
+	{0, "CF"}, {2, "PF"}, {4, "AF"}, {6, "ZF"}, {7, "SF"}, {8, "TF"}, {9, "IF"}, {10, "DF"}, {11, "OF"},
+	{14, "NT"}, {16, "RF"}, {17, "VM"}, {18, "AC"}, {19, "VIF"}, {20, "VIP"}, {21, "ID"},
+}
+
+// decodeFlags returns the names of the bits set in the value of the
+// RFLAGS/EFLAGS register.
+func decodeFlags(flags uint64) string {
+	r := []string{}
+	for _, f := range flagsBits {
+		if flags&(1<<f.bit) != 0 {
+			r = append(r, f.name)
+		}
+	}
+	r = append(r, fmt.Sprintf("IOPL=%d", (flags>>12)&3))
+	return "[" + strings.Join(r, " ") + "]"
+}
+
+// formatVectorLanes formats the contents of a vector register (in little
+// endian order) as a list of lanes of the specified format, starting with
+// the least significant lane.
+func formatVectorLanes(b []byte, format int) string {
+	sz := []int{1, 2, 4, 8, 4, 8}[format]
+	lanes := make([]string, 0, len(b)/sz)
+	for i := 0; i+sz <= len(b); i += sz {
+		lane := b[i : i+sz]
+		var s string
+		switch format {
+		case vectorInt8:
+			s = fmt.Sprintf("%d", int8(lane[0]))
+		case vectorInt16:
+			s = fmt.Sprintf("%d", int16(binary.LittleEndian.Uint16(lane)))
+		case vectorInt32:
+			s = fmt.Sprintf("%d", int32(binary.LittleEndian.Uint32(lane)))
+		case vectorInt64:
+			s = fmt.Sprintf("%d", int64(binary.LittleEndian.Uint64(lane)))
+		case vectorFloat32:
+			s = fmt.Sprintf("%g", math.Float32frombits(binary.LittleEndian.Uint32(lane)))
+		case vectorFloat64:
+			s = fmt.Sprintf("%g", math.Float64frombits(binary.LittleEndian.Uint64(lane)))
+		}
+		lanes = append(lanes, s)
+	}
+	return "{" + strings.Join(lanes, " ") + "}"
+}
+
+// isX87Register returns true if name is one of the 80 bit registers of the
+// x87 floating point unit, ST(0) through ST(7).
+func isX87Register(name string) bool {
+	return strings.HasPrefix(strings.ToUpper(name), "ST(")
+}
+
+// formatX87 formats the contents of an 80 bit x87 register (in little
+// endian order) as a floating point number.
+func formatX87(b []byte) (string, bool) {
+	if len(b) > 10 {
+		return "", false
+	}
+	var buf [10]byte
+	copy(buf[:], b)
+	mant := binary.LittleEndian.Uint64(buf[:8])
+	se := binary.LittleEndian.Uint16(buf[8:])
+	exp := int(se & 0x7fff)
+	sign := 1.0
+	if se&0x8000 != 0 {
+		sign = -1
+	}
+	var x float64
+	switch {
+	case exp == 0x7fff && mant<<1 == 0:
+		x = math.Inf(int(sign))
+	case exp == 0x7fff:
+		x = math.NaN()
+	case exp == 0:
+		// denormal
+		x = sign * math.Ldexp(float64(mant), -16382-63)
+	default:
+		x = sign * math.Ldexp(float64(mant), exp-16383-63)
+	}
+	return fmt.Sprintf("%g", x), true
+}
+
+// describeRegister returns the value of the register and a description of
+// its contents, according to the settings of the registers panel.
+func describeRegister(reg string, val string) (string, string) {
+	value, descr := splitRegisterValue(val)
+	b, ok := registerBytes(value)
+	if !ok {
+		return value, descr
+	}
+	switch {
+	case isFlagsRegister(reg) && len(b) <= 8:
+		var buf [8]byte
+		copy(buf[:], b)
+		return value, decodeFlags(binary.LittleEndian.Uint64(buf[:]))
+	case isX87Register(reg):
+		if s, ok := formatX87(b); ok {
+			return value, s
+		}
+		return value, descr
+	case len(b) > 8:
+		return value, formatVectorLanes(b, regsPanel.vectorFormat)
+	}
+	return value, descr
+}
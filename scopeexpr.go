@@ -1,11 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"unicode"
 
 	"go.starlark.net/starlark"
@@ -16,11 +19,15 @@ import (
 
 // ScopedExpr represents an expression to be evaluated in a specified scope.
 type ScopedExpr struct {
-	Kind ScopeExprKind
-	Gid  int            // goroutine id (-1 for current goroutine)
-	Fid  int            // frame id (-1 for current goroutine)
-	Foff int            // frame offset (will search for this specified frame offset or return an error otherwise)
-	Fre  *regexp.Regexp // frame regular expression (will search for a frame in a function matching this regular expression)
+	Kind    ScopeExprKind
+	Gid     int               // goroutine id (-1 for current goroutine)
+	Glabels map[string]string // goroutine labels (will search for the goroutine with these pprof labels)
+	Gre     *regexp.Regexp    // goroutine regular expression (will search for the first goroutine whose user location matches this regular expression)
+	Gall    bool              // evaluate in all goroutines that have the specified frame
+	Tid     int               // thread id (-1 for current thread)
+	Fid     int               // frame id (-1 for current goroutine)
+	Foff    int               // frame offset (will search for this specified frame offset or return an error otherwise)
+	Fre     *regexp.Regexp    // frame regular expression (will search for a frame in a function matching this regular expression)
 
 	DeferredCall int // deferred call index
 
@@ -42,7 +49,7 @@ func ParseScopedExpr(in string) ScopedExpr {
 			continue
 		}
 		if ch != '@' {
-			return ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Fid: -1, DeferredCall: -1, EvalExpr: strings.TrimSpace(in)}
+			return ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Tid: -1, Fid: -1, DeferredCall: -1, EvalExpr: strings.TrimSpace(in)}
 		} else {
 			in = in[i:]
 			break
@@ -55,9 +62,9 @@ func ParseScopedExpr(in string) ScopedExpr {
 
 	in = in[1:]
 
-	r := ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Fid: -1, DeferredCall: 0}
+	r := ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Tid: -1, Fid: -1, DeferredCall: 0}
 	first := true
-	var gseen, fseen, dseen bool
+	var gseen, tseen, fseen, dseen bool
 
 	for {
 		if len(in) == 0 {
@@ -66,22 +73,72 @@ func ParseScopedExpr(in string) ScopedExpr {
 		switch in[0] {
 		case 'g':
 			in = in[1:]
-			if gseen || len(in) == 0 {
+			if gseen {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate goroutine specifier"}
+			}
+			if len(in) == 0 {
 				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "no argument for 'g'"}
 			}
+			if tseen {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "can not specify both 'g' and 't'"}
+			}
 			gseen = true
+			switch in[0] {
+			case '*':
+				r.Gall = true
+				in = in[1:]
+			case '{':
+				var err error
+				r.Glabels, in, err = scopeReadLabels(in[1:])
+				if err != nil {
+					return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: err.Error()}
+				}
+			case '/':
+				var s string
+				s, in = scopeReadDelim('/', in[1:])
+				var err error
+				r.Gre, err = regexp.Compile(s)
+				if err != nil {
+					return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: fmt.Sprintf("could not compile regexp: %v", err)}
+				}
+			default:
+				var ok bool
+				in, r.Gid, ok = scopeReadNumber(in)
+				if !ok {
+					return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid argument for 'g'"}
+				}
+				if r.Gid < 0 {
+					return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid (negative) argument for 'g'"}
+				}
+			}
+
+		case 't':
+			in = in[1:]
+			if tseen {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate thread specifier"}
+			}
+			if len(in) == 0 {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "no argument for 't'"}
+			}
+			if gseen {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "can not specify both 'g' and 't'"}
+			}
+			tseen = true
 			var ok bool
-			in, r.Gid, ok = scopeReadNumber(in)
+			in, r.Tid, ok = scopeReadNumber(in)
 			if !ok {
-				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid argument for 'g'"}
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid argument for 't'"}
 			}
-			if r.Gid < 0 {
-				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid (negative) argument for 'g'"}
+			if r.Tid < 0 {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid (negative) argument for 't'"}
 			}
 
 		case 'f':
 			in = in[1:]
-			if fseen || len(in) == 0 {
+			if fseen {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate frame specifier"}
+			}
+			if len(in) == 0 {
 				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "no argument for 'f'"}
 			}
 			fseen = true
@@ -109,7 +166,10 @@ func ParseScopedExpr(in string) ScopedExpr {
 
 		case 'd':
 			in = in[1:]
-			if dseen || len(in) == 0 {
+			if dseen {
+				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate deferred call specifier"}
+			}
+			if len(in) == 0 {
 				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "no argument for 'd'"}
 			}
 			dseen = true
@@ -126,8 +186,15 @@ func ParseScopedExpr(in string) ScopedExpr {
 			if first {
 				return ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "nothing after @"}
 			}
-			in = in[1:]
-			r.EvalExpr = strings.TrimSpace(in)
+			in = strings.TrimSpace(in[1:])
+			if len(in) > 0 && in[0] == '@' {
+				// scope expressions can be split in multiple parts, for
+				// example "@g* @f/handler/ expr"
+				in = in[1:]
+				first = true
+				continue
+			}
+			r.EvalExpr = in
 			return r
 
 		default:
@@ -221,13 +288,35 @@ func scopeReadDelim(delim rune, in string) (out, rest string) {
 	return string(r), ""
 }
 
+// scopeReadLabels reads a list of comma separated key=value pairs
+// terminated by '}'.
+func scopeReadLabels(in string) (labels map[string]string, rest string, err error) {
+	end := strings.IndexByte(in, '}')
+	if end < 0 {
+		return nil, in, errors.New("unterminated label selector")
+	}
+	labels = map[string]string{}
+	for _, kv := range strings.Split(in[:end], ",") {
+		eq := strings.IndexByte(kv, '=')
+		if eq < 0 {
+			return nil, in, fmt.Errorf("invalid label selector %q", kv)
+		}
+		k, v := strings.TrimSpace(kv[:eq]), strings.TrimSpace(kv[eq+1:])
+		if k == "" {
+			return nil, in, fmt.Errorf("invalid label selector %q", kv)
+		}
+		labels[k] = v
+	}
+	return labels, in[end+1:], nil
+}
+
 func exprIsScoped(expr string) bool {
 	se := ParseScopedExpr(expr)
 	switch se.Kind {
 	case InvalidScopeExpr:
 		return true
 	case NormalScopeExpr:
-		return se.Gid >= 0 || se.Fid >= 0
+		return se.Gid >= 0 || se.Glabels != nil || se.Gre != nil || se.Gall || se.Tid >= 0 || se.Fid >= 0
 	default:
 		return true
 	}
@@ -238,15 +327,24 @@ func evalScopedExpr(expr string, cfg api.LoadConfig) *api.Variable {
 
 	var gid, frame, deferredCall int
 
-	gid = se.Gid
+	if se.Kind == InvalidScopeExpr {
+		return &api.Variable{Name: expr, Unreadable: "syntax error: " + se.EvalExpr}
+	}
+
+	if se.Gall {
+		v, _ := evalFanOutExpr(expr, &se, cfg)
+		return v
+	}
+
+	gid, err := findScopeGoroutine(&se)
+	if err != nil {
+		return &api.Variable{Name: expr, Unreadable: err.Error()}
+	}
 	if gid < 0 {
 		gid = curGid
 	}
 
 	switch se.Kind {
-	case InvalidScopeExpr:
-		return &api.Variable{Name: expr, Unreadable: "syntax error: " + se.EvalExpr}
-
 	case NormalScopeExpr:
 		frame = se.Fid
 		if frame < 0 {
@@ -305,6 +403,12 @@ func convertStarlarkToVariable(expr string, sv starlark.Value) *api.Variable {
 
 	case starbind.WrappedVariable:
 		return sv.UnwrapVariable()
+	case starlark.Bool:
+		s := "false"
+		if sv {
+			s = "true"
+		}
+		return &api.Variable{Name: expr, Kind: reflect.Bool, Type: "bool", RealType: "bool", Value: s}
 	case starlark.String:
 		return &api.Variable{Name: expr, Kind: reflect.String, Type: "string", RealType: "string", Len: int64(len(string(sv))), Value: string(sv)}
 	default:
@@ -313,12 +417,208 @@ func convertStarlarkToVariable(expr string, sv starlark.Value) *api.Variable {
 	}
 }
 
+// findScopeGoroutine returns the id of the goroutine selected by se, or
+// -1 if se does not select a goroutine.
+func findScopeGoroutine(se *ScopedExpr) (int, error) {
+	switch {
+	case se.Glabels != nil || se.Gre != nil:
+		gs, err := listScopeGoroutines()
+		if err != nil {
+			return -1, err
+		}
+		var found []int
+		for _, g := range gs {
+			if se.Glabels != nil && goroutineLabelsMatch(g.Labels, se.Glabels) {
+				found = append(found, g.ID)
+			}
+			if se.Gre != nil && goroutineLocationMatches(g, se.Gre) {
+				// the first matching goroutine is used
+				return g.ID, nil
+			}
+		}
+		switch len(found) {
+		case 0:
+			return -1, errors.New("could not find specified goroutine")
+		case 1:
+			return found[0], nil
+		default:
+			return -1, fmt.Errorf("ambiguous goroutine selector, %d goroutines match", len(found))
+		}
+
+	case se.Tid >= 0:
+		th, err := client.GetThread(se.Tid)
+		if err != nil {
+			return -1, err
+		}
+		if th.GoroutineID == 0 && se.Tid != curThread {
+			return -1, fmt.Errorf("thread %d is not running a goroutine", se.Tid)
+		}
+		if th.GoroutineID == 0 {
+			return -1, nil
+		}
+		return th.GoroutineID, nil
+	}
+	return se.Gid, nil
+}
+
+// scopeGoroutines caches the list of goroutines used to resolve goroutine
+// specifiers, it is cleared every time the target stops.
+var scopeGoroutines struct {
+	mu sync.Mutex
+	gs []*api.Goroutine // sorted by ID, nil if not loaded
+}
+
+// listScopeGoroutines returns the list of goroutines sorted by ID, the
+// list is loaded at most once per stop.
+func listScopeGoroutines() ([]*api.Goroutine, error) {
+	scopeGoroutines.mu.Lock()
+	defer scopeGoroutines.mu.Unlock()
+	if scopeGoroutines.gs != nil {
+		return scopeGoroutines.gs, nil
+	}
+	gs, err := client.ListGoroutines(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Sort(goroutinesByID(gs))
+	scopeGoroutines.gs = gs
+	return gs, nil
+}
+
+func clearScopeGoroutines() {
+	scopeGoroutines.mu.Lock()
+	scopeGoroutines.gs = nil
+	scopeGoroutines.mu.Unlock()
+}
+
+func goroutineLabelsMatch(labels, tgt map[string]string) bool {
+	for k, v := range tgt {
+		if lv, ok := labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// goroutineLocationMatches returns true if the function or the file:line
+// of the user location of g match rx.
+func goroutineLocationMatches(g *api.Goroutine, rx *regexp.Regexp) bool {
+	loc := &g.UserCurrentLoc
+	if rx.MatchString(loc.Function.Name()) {
+		return true
+	}
+	return rx.MatchString(fmt.Sprintf("%s:%d", loc.File, loc.Line))
+}
+
+// evalFanOutExpr evaluates the expression of se in every goroutine that
+// has the frame specified by se. The result is a slice with one struct for
+// each goroutine, containing the goroutine ID, the frame and the value of
+// the expression. At most cfg.MaxArrayValues goroutines are included, if
+// more goroutines could have been included truncated is set.
+func evalFanOutExpr(expr string, se *ScopedExpr, cfg api.LoadConfig) (v *api.Variable, truncated bool) {
+	if len(se.EvalExpr) > 0 && se.EvalExpr[0] == '$' {
+		return &api.Variable{Name: expr, Unreadable: "starlark expressions can not be evaluated in all goroutines"}, false
+	}
+	gs, err := listScopeGoroutines()
+	if err != nil {
+		return &api.Variable{Name: expr, Unreadable: err.Error()}, false
+	}
+
+	var rows []api.Variable
+	depth := fanOutDepth(se)
+	for _, g := range gs {
+		if cfg.MaxArrayValues > 0 && len(rows) >= cfg.MaxArrayValues {
+			truncated = true
+			break
+		}
+		frames, err := client.Stacktrace(g.ID, depth, 0, nil)
+		if err != nil {
+			continue
+		}
+		frame := fanOutFrame(frames, se)
+		if frame < 0 {
+			continue
+		}
+		v, err := client.EvalVariable(api.EvalScope{g.ID, frame, se.DeferredCall}, se.EvalExpr, cfg)
+		if err != nil {
+			v = &api.Variable{Unreadable: err.Error()}
+		}
+		rows = append(rows, fanOutRow(g.ID, frame, frames[frame].Function.Name(), v))
+	}
+	return fanOutResult(expr, rows), truncated
+}
+
+// fanOutDepth returns the depth of the stacktraces needed to find the frame
+// specified by se in each goroutine.
+func fanOutDepth(se *ScopedExpr) int {
+	if se.Kind == NormalScopeExpr {
+		// a stacktrace of depth n contains frames 0 through n
+		if se.Fid < 0 {
+			return 0
+		}
+		return se.Fid
+	}
+	return 100
+}
+
+// fanOutFrame returns the index of the frame in which the expression of se
+// is evaluated, or -1 if frames does not contain the frame specified by
+// se.
+func fanOutFrame(frames []api.Stackframe, se *ScopedExpr) int {
+	switch se.Kind {
+	case NormalScopeExpr:
+		frame := se.Fid
+		if frame < 0 {
+			frame = 0
+		}
+		if frame >= len(frames) {
+			return -1
+		}
+		return frame
+	case FrameOffsetScopeExpr:
+		return matchFrame(frames, int64(se.Foff), nil)
+	case FrameRegexScopeExpr:
+		return matchFrame(frames, 0, se.Fre)
+	}
+	return -1
+}
+
+// fanOutRow returns the row of the result of evalFanOutExpr for goroutine
+// gid, v is the value of the expression in frame, which executes function
+// fn.
+func fanOutRow(gid, frame int, fn string, v *api.Variable) api.Variable {
+	vcopy := *v
+	vcopy.Name = "Value"
+	framestr := fmt.Sprintf("%d %s", frame, fn)
+	return api.Variable{
+		Type: "goroutine result", RealType: "goroutine result",
+		Kind: reflect.Struct,
+		Len:  3,
+		Children: []api.Variable{
+			{Name: "Goroutine", Type: "int", RealType: "int", Kind: reflect.Int, Value: strconv.Itoa(gid)},
+			{Name: "Frame", Type: "string", RealType: "string", Kind: reflect.String, Len: int64(len(framestr)), Value: framestr},
+			vcopy,
+		},
+	}
+}
+
+// fanOutResult returns the result of evalFanOutExpr.
+func fanOutResult(expr string, rows []api.Variable) *api.Variable {
+	return &api.Variable{Name: expr, Type: "[]goroutine result", RealType: "[]goroutine result", Kind: reflect.Slice, Len: int64(len(rows)), Cap: int64(len(rows)), Children: rows}
+}
+
 func findFrameOffset(gid int, frameOffset int64, rx *regexp.Regexp) (frame int) {
 	frames, err := client.Stacktrace(gid, 100, 0, nil)
 	if err != nil {
 		return -1
 	}
+	return matchFrame(frames, frameOffset, rx)
+}
 
+// matchFrame returns the index of the first frame executing a function
+// matching rx or, if rx is nil, the first frame with the specified frame
+// offset.
+func matchFrame(frames []api.Stackframe, frameOffset int64, rx *regexp.Regexp) (frame int) {
 	for i := range frames {
 		if rx != nil {
 			if rx.FindStringIndex(frames[i].Function.Name()) != nil {
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"reflect"
 	"regexp"
 	"testing"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
 )
 
 type scopeTestCase struct {
@@ -53,3 +56,111 @@ func TestScopeSpecs(t *testing.T) {
 		}
 	}
 }
+
+func TestScopeSelectors(t *testing.T) {
+	for _, tc := range []scopeTestCase{
+		{"@g{request_id=42} req.State", ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Glabels: map[string]string{"request_id": "42"}, Tid: -1, Fid: -1, EvalExpr: "req.State"}},
+		{"@g{a=1, b = x}f2 expr", ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Glabels: map[string]string{"a": "1", "b": "x"}, Tid: -1, Fid: 2, EvalExpr: "expr"}},
+		{"@g/main\\.worker/ expr", ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Gre: regexp.MustCompile("main\\.worker"), Tid: -1, Fid: -1, EvalExpr: "expr"}},
+		{"@g/server.go:1[0-9]/f/handle/ expr", ScopedExpr{Kind: FrameRegexScopeExpr, Gid: -1, Gre: regexp.MustCompile("server.go:1[0-9]"), Tid: -1, Fre: regexp.MustCompile("handle"), EvalExpr: "expr"}},
+		{"@t1234 expr", ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Tid: 1234, Fid: -1, EvalExpr: "expr"}},
+		{"@t4337f1 expr", ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Tid: 4337, Fid: 1, EvalExpr: "expr"}},
+		{"@g* @f/handler/ req.URL.Path", ScopedExpr{Kind: FrameRegexScopeExpr, Gid: -1, Gall: true, Tid: -1, Fre: regexp.MustCompile("handler"), EvalExpr: "req.URL.Path"}},
+		{"@g*f/handler/ req.URL.Path", ScopedExpr{Kind: FrameRegexScopeExpr, Gid: -1, Gall: true, Tid: -1, Fre: regexp.MustCompile("handler"), EvalExpr: "req.URL.Path"}},
+		{"@g*  @f2  expr", ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Gall: true, Tid: -1, Fid: 2, EvalExpr: "expr"}},
+		{"@g* expr", ScopedExpr{Kind: NormalScopeExpr, Gid: -1, Gall: true, Tid: -1, Fid: -1, EvalExpr: "expr"}},
+		{"@g* @g1 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate goroutine specifier"}},
+		{"@t1t2 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate thread specifier"}},
+		{"@f1 @f2 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate frame specifier"}},
+		{"@d1d1 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "duplicate deferred call specifier"}},
+		{"@g", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "no argument for 'g'"}},
+		{"@g* @ expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "nothing after @"}},
+		{"@g{request_id=42 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "unterminated label selector"}},
+		{"@g{request_id} expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid label selector \"request_id\""}},
+		{"@g{=1} expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid label selector \"=1\""}},
+		{"@g1t2 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "can not specify both 'g' and 't'"}},
+		{"@t2g1 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "can not specify both 'g' and 't'"}},
+		{"@t-1 expr", ScopedExpr{Kind: InvalidScopeExpr, EvalExpr: "invalid (negative) argument for 't'"}},
+	} {
+		se := ParseScopedExpr(tc.input)
+		if se.Kind != tc.Kind || se.EvalExpr != tc.EvalExpr {
+			t.Fatalf("error parsing %q, expected %#v got %#v", tc.input, tc.ScopedExpr, se)
+		}
+		if se.Kind == InvalidScopeExpr {
+			continue
+		}
+		if se.Gid != tc.Gid || se.Gall != tc.Gall || se.Tid != tc.Tid || (se.Kind == NormalScopeExpr && se.Fid != tc.Fid) || !reflect.DeepEqual(se.Glabels, tc.Glabels) || (se.Gre == nil) != (tc.Gre == nil) || (se.Gre != nil && se.Gre.String() != tc.Gre.String()) {
+			t.Fatalf("error parsing %q, expected %#v got %#v", tc.input, tc.ScopedExpr, se)
+		}
+		if !exprIsScoped(tc.input) {
+			t.Errorf("%q not scoped", tc.input)
+		}
+	}
+
+	labels := map[string]string{"request_id": "42", "user": "x"}
+	if !goroutineLabelsMatch(labels, map[string]string{"request_id": "42"}) || goroutineLabelsMatch(labels, map[string]string{"request_id": "43"}) || goroutineLabelsMatch(nil, map[string]string{"user": ""}) {
+		t.Errorf("wrong label matching")
+	}
+
+	g := &api.Goroutine{UserCurrentLoc: api.Location{File: "/src/server.go", Line: 12, Function: &api.Function{Name_: "main.(*Server).handle"}}}
+	for _, tc := range []struct {
+		re    string
+		match bool
+	}{
+		{"handle$", true},
+		{"server.go:12$", true},
+		{"server.go:1$", false},
+		{"main\\.worker", false},
+	} {
+		if goroutineLocationMatches(g, regexp.MustCompile(tc.re)) != tc.match {
+			t.Errorf("matching %q: expected %v", tc.re, tc.match)
+		}
+	}
+}
+
+func TestFanOut(t *testing.T) {
+	frame := func(fn string, off int64) api.Stackframe {
+		return api.Stackframe{Location: api.Location{Function: &api.Function{Name_: fn}}, FrameOffset: off}
+	}
+	frames := []api.Stackframe{frame("runtime.gopark", -16), frame("main.(*Server).handler", -80), frame("main.serve", -160)}
+
+	for _, tc := range []struct {
+		input string
+		frame int
+		depth int
+	}{
+		{"@g* expr", 0, 0},
+		{"@g*f2 expr", 2, 2},
+		{"@g*f3 expr", -1, 3},
+		{"@g*f-80 expr", 1, 100},
+		{"@g*f-96 expr", -1, 100},
+		{"@g* @f/handler/ expr", 1, 100},
+		{"@g* @f/worker/ expr", -1, 100},
+	} {
+		se := ParseScopedExpr(tc.input)
+		if frame := fanOutFrame(frames, &se); frame != tc.frame {
+			t.Errorf("%q: expected frame %d got %d", tc.input, tc.frame, frame)
+		}
+		if depth := fanOutDepth(&se); depth != tc.depth {
+			t.Errorf("%q: expected depth %d got %d", tc.input, tc.depth, depth)
+		}
+	}
+
+	v := &api.Variable{Name: "req.URL.Path", Type: "string", Kind: reflect.String, Value: "/index", Len: 6}
+	row := fanOutRow(12, 1, "main.(*Server).handler", v)
+	if len(row.Children) != 3 || row.Children[0].Value != "12" || row.Children[1].Value != "1 main.(*Server).handler" || row.Children[2].Name != "Value" || row.Children[2].Value != "/index" {
+		t.Errorf("wrong row %#v", row)
+	}
+	if v.Name != "req.URL.Path" {
+		t.Errorf("value modified by fanOutRow")
+	}
+
+	rows := []api.Variable{row, fanOutRow(13, 0, "main.serve", &api.Variable{Unreadable: "could not find symbol"})}
+	r := fanOutResult("@g* @f/handler/ req.URL.Path", rows)
+	if r.Kind != reflect.Slice || r.Len != 2 || len(r.Children) != 2 || r.Children[1].Children[2].Unreadable != "could not find symbol" {
+		t.Errorf("wrong result %#v", r)
+	}
+	if !isStructSlice(r) {
+		t.Errorf("result can not be displayed as a table")
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+)
+
+// scriptFormatter is a formatter registered by a script with
+// register_formatter, it applies to all variables whose type is equal to
+// pattern or matches it as a regular expression.
+type scriptFormatter struct {
+	pattern string
+	rx      *regexp.Regexp // nil if pattern is not a valid regular expression
+	fn      func(v *api.Variable) (string, error)
+}
+
+type scriptFormatterKey struct {
+	f    *scriptFormatter
+	addr uintptr
+	typ  string
+}
+
+var scriptFormatters = struct {
+	mu    sync.Mutex
+	list  []*scriptFormatter
+	cache map[scriptFormatterKey]string // formatted values, cleared every time the target stops
+}{cache: map[scriptFormatterKey]string{}}
+
+func (s starlarkContext) RegisterFormatter(pattern string, fn func(v *api.Variable) (string, error)) error {
+	if pattern == "" {
+		return errors.New("empty formatter pattern")
+	}
+	f := &scriptFormatter{pattern: pattern, fn: fn}
+	f.rx, _ = regexp.Compile("^(?:" + pattern + ")$")
+
+	scriptFormatters.mu.Lock()
+	defer scriptFormatters.mu.Unlock()
+	scriptFormatters.cache = map[scriptFormatterKey]string{}
+	for i := range scriptFormatters.list {
+		if scriptFormatters.list[i].pattern == pattern {
+			scriptFormatters.list[i] = f
+			return nil
+		}
+	}
+	scriptFormatters.list = append(scriptFormatters.list, f)
+	return nil
+}
+
+func clearScriptFormatterCache() {
+	scriptFormatters.mu.Lock()
+	scriptFormatters.cache = map[scriptFormatterKey]string{}
+	scriptFormatters.mu.Unlock()
+}
+
+// findScriptFormatter returns the formatter for typ, formatters whose
+// pattern is equal to typ have precedence over regular expressions, which
+// are tried in the order they were registered.
+func findScriptFormatter(typ string) *scriptFormatter {
+	scriptFormatters.mu.Lock()
+	defer scriptFormatters.mu.Unlock()
+	for _, f := range scriptFormatters.list {
+		if f.pattern == typ {
+			return f
+		}
+	}
+	for _, f := range scriptFormatters.list {
+		if f.rx != nil && f.rx.MatchString(typ) {
+			return f
+		}
+	}
+	return nil
+}
+
+// format formats v, errors are returned as the formatted value.
+func (f *scriptFormatter) format(v *api.Variable) string {
+	key := scriptFormatterKey{f, v.Addr, v.Type}
+	if v.Addr != 0 {
+		scriptFormatters.mu.Lock()
+		s, ok := scriptFormatters.cache[key]
+		scriptFormatters.mu.Unlock()
+		if ok {
+			return s
+		}
+	}
+	s, err := f.fn(v)
+	if err != nil {
+		s = fmt.Sprintf("formatter error (%s): %v", f.pattern, err)
+	}
+	if v.Addr != 0 {
+		scriptFormatters.mu.Lock()
+		scriptFormatters.cache[key] = s
+		scriptFormatters.mu.Unlock()
+	}
+	return s
+}
+
+// applyScriptFormatters formats the variables in the trees of vs that have
+// a script formatter, it is called by loadVariable and loadVariables.
+func applyScriptFormatters(vs ...*Variable) {
+	for _, v := range vs {
+		if v == nil {
+			continue
+		}
+		if v.scriptFmt != nil {
+			v.Value = v.scriptFmt.format(v.Variable)
+			v.formatted = true
+			v.scriptFmt = nil
+		}
+		applyScriptFormatters(v.Children...)
+	}
+}
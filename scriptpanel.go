@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"image"
+	"io"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/clipboard"
+	"github.com/aarzilli/nucular/label"
+
+	"github.com/aarzilli/gdlv/internal/starbind"
+)
+
+// scriptPanelCodes are the codes used to save panels defined by scripts
+// in a layout, see scriptPanelCode.
+const scriptPanelCodes = "bcehijmnopquvwxyzEFHIJKMNOPQRUVWXYZ"
+
+// scriptPanelCode returns the layout code for the script panel called
+// name. The code is derived from the name, so that saved layouts keep
+// working when scripts define their panels in a different order, if the
+// code is already used the next free code is returned.
+func scriptPanelCode(name string, used map[byte]string) (byte, bool) {
+	h := fnv.New32a()
+	io.WriteString(h, name)
+	start := int(h.Sum32() % uint32(len(scriptPanelCodes)))
+	for i := 0; i < len(scriptPanelCodes); i++ {
+		c := scriptPanelCodes[(start+i)%len(scriptPanelCodes)]
+		if _, ok := used[c]; !ok {
+			return c, true
+		}
+	}
+	return 0, false
+}
+
+// scriptPanel is an info panel defined by a script with the panel
+// builtin, its contents are computed by calling the render function
+// every time the target stops.
+type scriptPanel struct {
+	name      string
+	asyncLoad asyncLoad
+	render    func(out io.Writer) (*starbind.PanelContent, error)
+	content   *starbind.PanelContent
+	tree      []*dataTreeNode
+	id        int
+}
+
+var scriptPanels = struct {
+	mu      sync.Mutex
+	m       map[string]*scriptPanel
+	pending []*scriptPanel // panels registered by scripts that have not been added to the info panels yet
+}{m: map[string]*scriptPanel{}}
+
+// RegisterPanel is called by scripts while they are executing, new panels
+// are added to the info panels by the UI loop, see addPendingScriptPanels.
+func (s starlarkContext) RegisterPanel(name string, render func(out io.Writer) (*starbind.PanelContent, error)) error {
+	if name == "" || strings.IndexFunc(name, unicode.IsSpace) >= 0 {
+		return fmt.Errorf("invalid panel name %q", name)
+	}
+
+	scriptPanels.mu.Lock()
+	defer scriptPanels.mu.Unlock()
+
+	if p := scriptPanels.m[name]; p != nil {
+		p.asyncLoad.mu.Lock()
+		p.render = render
+		p.asyncLoad.mu.Unlock()
+		p.asyncLoad.clear()
+		return nil
+	}
+	if _, ok := infoNameToPanel[name]; ok {
+		return fmt.Errorf("panel %q already exists", name)
+	}
+
+	p := &scriptPanel{name: name, render: render}
+	p.asyncLoad.load = p.load
+	scriptPanels.m[name] = p
+	scriptPanels.pending = append(scriptPanels.pending, p)
+	wnd.Changed()
+	return nil
+}
+
+// addPendingScriptPanels adds the panels registered by scripts to the info
+// panels, it must be called by the UI loop.
+func addPendingScriptPanels() {
+	scriptPanels.mu.Lock()
+	defer scriptPanels.mu.Unlock()
+	for _, p := range scriptPanels.pending {
+		infoNameToPanel[p.name] = infoPanel{p.update, 0, &p.asyncLoad}
+		infoModes = append(infoModes, p.name)
+		if c, ok := scriptPanelCode(p.name, codeToInfoMode); ok {
+			codeToInfoMode[c] = p.name
+			infoModeToCode[p.name] = c
+		}
+	}
+	scriptPanels.pending = nil
+}
+
+// clearScriptPanels causes all panels defined by scripts to be rendered
+// again.
+func clearScriptPanels() {
+	scriptPanels.mu.Lock()
+	defer scriptPanels.mu.Unlock()
+	for _, p := range scriptPanels.m {
+		p.asyncLoad.clear()
+	}
+}
+
+func (p *scriptPanel) load(l *asyncLoad) {
+	l.mu.Lock()
+	render := p.render
+	l.mu.Unlock()
+	content, err := render(&editorWriter{true})
+	p.content = content
+	p.tree = nil
+	if content != nil {
+		p.tree = panelNodesToDataTree(content.Tree)
+	}
+	p.id++
+	l.done(err)
+}
+
+func panelNodesToDataTree(nodes []*starbind.PanelNode) []*dataTreeNode {
+	if len(nodes) == 0 {
+		return nil
+	}
+	r := make([]*dataTreeNode, len(nodes))
+	for i, n := range nodes {
+		r[i] = &dataTreeNode{Key: n.Name, Value: n.Value, Children: panelNodesToDataTree(n.Children)}
+	}
+	return r
+}
+
+func (p *scriptPanel) update(container *nucular.Window) {
+	w := p.asyncLoad.showRequest(container)
+	if w == nil {
+		return
+	}
+
+	content := p.content
+
+	if content.Text != "" {
+		for _, line := range strings.Split(strings.TrimRight(content.Text, "\n"), "\n") {
+			w.Row(posRowHeight).Dynamic(1)
+			w.Label(line, "LC")
+		}
+	}
+
+	if len(content.Columns) > 0 || len(content.Rows) > 0 {
+		if len(content.Columns) > 0 {
+			w.Row(posRowHeight).Static()
+			for _, col := range content.Columns {
+				w.LayoutFitWidth(p.id, 1)
+				w.LabelColored(col, "LC", linkColor)
+			}
+		}
+		for _, row := range content.Rows {
+			w.Row(posRowHeight).Static()
+			for _, cell := range row {
+				w.LayoutFitWidth(p.id, 1)
+				w.Label(cell, "LC")
+				if cw := w.ContextualOpen(0, image.Point{}, w.LastWidgetBounds, nil); cw != nil {
+					cw.Row(20).Dynamic(1)
+					if cw.MenuItem(label.TA("Copy value", "LC")) {
+						clipboard.Set(cell)
+					}
+				}
+			}
+		}
+	}
+
+	if len(p.tree) > 0 {
+		showDataTree(w, p.tree, 0)
+	}
+}
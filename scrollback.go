@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"sync"
 
 	"github.com/aarzilli/nucular/richtext"
@@ -34,6 +35,11 @@ func (w *editorWriter) Write(b []byte) (int, error) {
 		onNewline = b[len(b)-1] == '\n'
 	}
 
+	if TestScript != "" {
+		// there is no GUI
+		return os.Stderr.Write(b)
+	}
+
 	scrollbackMu.Lock()
 	if !scrollbackInitialized {
 		scrollbackPreInitWrite = append(scrollbackPreInitWrite, b...)
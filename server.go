@@ -41,6 +41,8 @@ type ServerDescr struct {
 	// connection to delve failed
 	connectionFailed bool
 	debugid          string
+	// if not nil receives the outcome of the first connection attempt
+	connected chan bool
 }
 
 var RemoveExecutable bool = true
@@ -81,6 +83,25 @@ func parseArguments() (descr ServerDescr) {
 
 	opts := parseOptions(os.Args)
 
+	if opts.cmd == testScriptCommand {
+		if len(opts.cmdArgs) < 2 {
+			usage("wrong number of arguments")
+		}
+		TestScript = opts.cmdArgs[0]
+		outer := opts
+		opts = parseOptions(append([]string{os.Args[0]}, opts.cmdArgs[1:]...))
+		if opts.buildDir == "" {
+			opts.buildDir = outer.buildDir
+		}
+		if opts.tags == "" {
+			opts.tags = outer.tags
+		}
+		switch opts.cmd {
+		case "attach", "core":
+			usage(fmt.Sprintf("can not use '%s' with '%s', the target is restarted before each test", opts.cmd, testScriptCommand))
+		}
+	}
+
 	optflags := []string{"-gcflags", "-N -l"}
 	ver, _ := goversion.Installed()
 	switch {
@@ -321,6 +342,7 @@ func (descr *ServerDescr) stdoutProcess(lenient bool) {
 	if first {
 		descr.connectionFailed = true
 		fmt.Fprintf(&scrollbackOut, "connection failed\n")
+		descr.notifyConnected(false)
 	}
 }
 
@@ -358,6 +380,9 @@ func (descr *ServerDescr) Rebuild() {
 		}
 		io.WriteString(sw, s)
 	}
+	if !descr.buildok {
+		descr.notifyConnected(false)
+	}
 	if descr.serverProcess == nil && descr.buildok {
 		lenient := false
 		for _, arg := range descr.dlvargs {
@@ -401,6 +426,7 @@ func (descr *ServerDescr) connectTo() {
 	var scrollbackOut = editorWriter{true}
 
 	if descr.connectString == "" {
+		descr.notifyConnected(false)
 		return
 	}
 
@@ -411,6 +437,7 @@ func (descr *ServerDescr) connectTo() {
 		client = nil
 		wnd.Unlock()
 		fmt.Fprintf(&scrollbackOut, "Could not connect: %v\n", err)
+		descr.notifyConnected(false)
 		return
 	}
 
@@ -448,9 +475,22 @@ func (descr *ServerDescr) connectTo() {
 		}
 
 		refreshState(refreshToFrameZero, clearStop, state)
+		descr.notifyConnected(err == nil && state != nil)
 	}()
 }
 
+// notifyConnected sends the outcome of a connection attempt to
+// descr.connected without blocking.
+func (descr *ServerDescr) notifyConnected(ok bool) {
+	if descr.connected == nil {
+		return
+	}
+	select {
+	case descr.connected <- ok:
+	default:
+	}
+}
+
 func continueToRuntimeMain() {
 	startupfn := conf.StartupFunc
 	if startupfn == "" {
@@ -506,6 +546,10 @@ func loadProgramInfo(out io.Writer) {
 	}
 
 	lastModExe = client.LastModified()
+	disassemblyPanel.symbols = nil
+	disassemblyPanel.globals = nil
+	disassemblyPanel.globalsLoaded = false
+	disassemblyPanel.globalSizes = nil
 
 	funcsPanel.id++
 	typesPanel.id++
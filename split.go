@@ -33,6 +33,7 @@ const (
 	infoCheckpoints     = "Checkpoints"
 	infoDeferredCalls   = "DeferredCalls"
 	infoAutoCheckpoints = "AutoCheckpoints"
+	infoAncestors       = "Ancestors"
 )
 
 type infoPanel struct {
@@ -44,7 +45,7 @@ type infoPanel struct {
 var infoNameToPanel map[string]infoPanel
 
 var infoModes = []string{
-	infoCommand, infoListing, infoDisassembly, infoGoroutines, infoStacktrace, infoLocals, infoGlobal, infoBps, infoThreads, infoRegisters, infoSources, infoFuncs, infoTypes, infoCheckpoints, infoDeferredCalls, infoAutoCheckpoints,
+	infoCommand, infoListing, infoDisassembly, infoGoroutines, infoStacktrace, infoLocals, infoGlobal, infoBps, infoThreads, infoRegisters, infoSources, infoFuncs, infoTypes, infoCheckpoints, infoDeferredCalls, infoAutoCheckpoints, infoAncestors,
 }
 
 var codeToInfoMode = map[byte]string{
@@ -64,6 +65,7 @@ var codeToInfoMode = map[byte]string{
 	'k': infoCheckpoints,
 	'd': infoDeferredCalls,
 	'A': infoAutoCheckpoints,
+	'a': infoAncestors,
 }
 
 var infoModeToCode = map[string]byte{}
@@ -87,6 +89,7 @@ func init() {
 	infoNameToPanel[infoCheckpoints] = infoPanel{updateCheckpoints, 0, &checkpointsPanel.asyncLoad}
 	infoNameToPanel[infoDeferredCalls] = infoPanel{updateDeferredCalls, 0, &stackPanel.asyncLoad}
 	infoNameToPanel[infoAutoCheckpoints] = infoPanel{updateAutoCheckpoints, 0, &autoCheckpointsPanel.asyncLoad}
+	infoNameToPanel[infoAncestors] = infoPanel{updateAncestors, 0, &ancestorsPanel.asyncLoad}
 
 	for k, v := range codeToInfoMode {
 		infoModeToCode[v] = k
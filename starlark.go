@@ -5,6 +5,11 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 
 	"go.starlark.net/starlark"
 
@@ -73,6 +78,118 @@ func (s starlarkContext) LoadConfig() api.LoadConfig {
 	return getVariableLoadConfig()
 }
 
+// ScriptPath returns the directories where modules loaded by starlark
+// scripts are searched, the ones in the configuration file first, followed
+// by the ones in $GDLV_STARLARK_PATH.
+func (s starlarkContext) ScriptPath() []string {
+	var r []string
+	for _, dir := range conf.StarlarkPath {
+		r = append(r, expandTilde(dir))
+	}
+	for _, dir := range filepath.SplitList(os.Getenv("GDLV_STARLARK_PATH")) {
+		if dir != "" {
+			r = append(r, expandTilde(dir))
+		}
+	}
+	return r
+}
+
+// runStarlarkHook calls the hook function name, if a script defined one.
+func runStarlarkHook(name string, args ...interface{}) {
+	if !StarlarkEnv.HasHook(name) {
+		return
+	}
+	defer wnd.Changed()
+	out := editorWriter{true}
+	if err := StarlarkEnv.CallHook(&out, name, args...); err != nil {
+		fmt.Fprintf(&out, "Error executing %s: %v\n", name, err)
+	}
+}
+
+func runStopHooks(state *api.DebuggerState) {
+	runStarlarkHook(starbind.OnStopHook, state)
+	if state.CurrentThread != nil && state.CurrentThread.Breakpoint != nil {
+		runStarlarkHook(starbind.OnBreakpointHook, state.CurrentThread.Breakpoint, state)
+	}
+}
+
+// exitHookCalled is set after on_exit is called so that it is called only
+// once for each run of the target process.
+var exitHookCalled struct {
+	sync.Mutex
+	called bool
+}
+
+// runExitHook calls on_exit, status is the exit status of the target
+// process or nil if it did not exit.
+func runExitHook(status interface{}) {
+	exitHookCalled.Lock()
+	called := exitHookCalled.called
+	exitHookCalled.called = true
+	exitHookCalled.Unlock()
+	if !called {
+		runStarlarkHook(starbind.OnExitHook, status)
+	}
+}
+
+func runRestartHook() {
+	exitHookCalled.Lock()
+	exitHookCalled.called = false
+	exitHookCalled.Unlock()
+	runStarlarkHook(starbind.OnRestartHook)
+}
+
+// parseExitStatus parses the exit status out of a process exited error.
+func parseExitStatus(err error) (int, bool) {
+	const exitedWithStatus = " has exited with status "
+	msg := err.Error()
+	idx := strings.Index(msg, exitedWithStatus)
+	if idx < 0 {
+		return 0, false
+	}
+	status, err := strconv.Atoi(strings.TrimSpace(msg[idx+len(exitedWithStatus):]))
+	return status, err == nil
+}
+
+const maxStarlarkHistory = 1000
+
+func starlarkHistoryLoc() string {
+	return configLoc() + ".star_history"
+}
+
+// loadStarlarkHistory reads the history of the starlark REPL. Entries are
+// saved quoted, one per line, so that they can span multiple lines.
+func loadStarlarkHistory() []string {
+	r := []string{""}
+	buf, err := ioutil.ReadFile(starlarkHistoryLoc())
+	if err != nil {
+		return r
+	}
+	for _, line := range strings.Split(string(buf), "\n") {
+		if entry, err := strconv.Unquote(line); err == nil {
+			r = append(r, entry)
+		}
+	}
+	if len(r) > maxStarlarkHistory {
+		r = append(r[:1], r[len(r)-maxStarlarkHistory:]...)
+		var out strings.Builder
+		for _, entry := range r[1:] {
+			fmt.Fprintln(&out, strconv.Quote(entry))
+		}
+		ioutil.WriteFile(starlarkHistoryLoc(), []byte(out.String()), 0660)
+	}
+	return r
+}
+
+func appendStarlarkHistory(entry string) {
+	fh, err := os.OpenFile(starlarkHistoryLoc(), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0660)
+	if err != nil {
+		return
+	}
+	defer fh.Close()
+	fmt.Fprintln(fh, strconv.Quote(entry))
+}
+
 const defaultInitFile = `
 def command_find_array(arr, pred):
 	"""Calls pred for each element of the array or slice 'arr' returns the index of
@@ -162,3 +279,24 @@ func executeInit() {
 	}
 	fmt.Fprintf(&scrollbackOut, "done\n")
 }
+
+func starlarkInitDirLoc() string {
+	return configLoc() + ".star.d"
+}
+
+// executeInitDir executes all starlark scripts in the init directory, in
+// alphabetical order.
+func executeInitDir() {
+	scrollbackOut := editorWriter{true}
+	paths, _ := filepath.Glob(filepath.Join(starlarkInitDirLoc(), "*.star"))
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&scrollbackOut, "Loading %q...", path)
+		_, err := StarlarkEnv.Execute(&scrollbackOut, path, nil, "main", nil, nil)
+		if err != nil {
+			fmt.Fprintf(&scrollbackOut, "\n%v\n", err)
+			continue
+		}
+		fmt.Fprintf(&scrollbackOut, "done\n")
+	}
+}
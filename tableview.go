@@ -0,0 +1,364 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/parser"
+	"go/token"
+	"image"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/clipboard"
+	"github.com/aarzilli/nucular/label"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+	"github.com/aarzilli/gdlv/internal/prettyprint"
+)
+
+// tableLoadConfig is used to load slices of structs in the table viewer,
+// nested structs are loaded so that they can be flattened.
+func tableLoadConfig(n int) api.LoadConfig {
+	return api.LoadConfig{true, 2, 64, n, -1}
+}
+
+// isStructSlice returns true if v is a slice or array of structs.
+func isStructSlice(v *api.Variable) bool {
+	return (v.Kind == reflect.Slice || v.Kind == reflect.Array) && len(v.Children) > 0 && v.Children[0].Kind == reflect.Struct
+}
+
+// structTable is a slice of structs flattened into a table, with one column
+// for each field, nested fields are named by their path (for example a.b).
+type structTable struct {
+	columns []string
+	colidx  map[string]int
+	rows    [][]string
+}
+
+func newStructTable(elems []api.Variable) *structTable {
+	t := &structTable{colidx: map[string]int{}}
+	t.appendRows(elems)
+	return t
+}
+
+func (t *structTable) appendRows(elems []api.Variable) {
+	for i := range elems {
+		var row []string
+		flattenStruct("", &elems[i], func(path, value string) {
+			idx, ok := t.colidx[path]
+			if !ok {
+				idx = len(t.columns)
+				t.colidx[path] = idx
+				t.columns = append(t.columns, path)
+			}
+			for len(row) <= idx {
+				row = append(row, "")
+			}
+			row[idx] = value
+		})
+		t.rows = append(t.rows, row)
+	}
+}
+
+func (t *structTable) cell(row, col int) string {
+	if col < len(t.rows[row]) {
+		return t.rows[row][col]
+	}
+	return ""
+}
+
+func flattenStruct(prefix string, v *api.Variable, add func(path, value string)) {
+	for i := range v.Children {
+		field := &v.Children[i]
+		path := prefix + field.Name
+		switch {
+		case field.Kind == reflect.Struct && len(field.Children) > 0:
+			flattenStruct(path+".", field, add)
+		case field.Kind == reflect.Ptr && len(field.Children) == 1 && field.Children[0].Kind == reflect.Struct && len(field.Children[0].Children) > 0:
+			flattenStruct(path+".", &field.Children[0], add)
+		default:
+			add(path, prettyprint.Singleline(field, false, false))
+		}
+	}
+}
+
+// sortRows sorts the row indexes in order by the values of column col,
+// numbers are compared numerically.
+func (t *structTable) sortRows(order []int, col int, desc bool) {
+	sort.SliceStable(order, func(i, j int) bool {
+		a, b := t.cell(order[i], col), t.cell(order[j], col)
+		if desc {
+			a, b = b, a
+		}
+		af, aerr := strconv.ParseFloat(a, 64)
+		bf, berr := strconv.ParseFloat(b, 64)
+		if aerr == nil && berr == nil {
+			return af < bf
+		}
+		return a < b
+	})
+}
+
+// tableFilter is a boolean expression, using Go syntax, on the columns of
+// a structTable. Columns are referred to by their path.
+type tableFilter struct {
+	expr ast.Expr
+}
+
+func compileTableFilter(s string) (*tableFilter, error) {
+	expr, err := parser.ParseExpr(s)
+	if err != nil {
+		return nil, err
+	}
+	return &tableFilter{expr}, nil
+}
+
+func (f *tableFilter) match(t *structTable, row int) (ok bool, err error) {
+	defer func() {
+		// go/constant panics on mismatched operands
+		if ierr := recover(); ierr != nil {
+			ok, err = false, fmt.Errorf("%v", ierr)
+		}
+	}()
+	v, err := f.eval(f.expr, t, row)
+	if err != nil {
+		return false, err
+	}
+	if v.Kind() != constant.Bool {
+		return false, errors.New("filter expression is not boolean")
+	}
+	return constant.BoolVal(v), nil
+}
+
+func (f *tableFilter) eval(expr ast.Expr, t *structTable, row int) (constant.Value, error) {
+	switch expr := expr.(type) {
+	case *ast.ParenExpr:
+		return f.eval(expr.X, t, row)
+
+	case *ast.BasicLit:
+		return constant.MakeFromLiteral(expr.Value, expr.Kind, 0), nil
+
+	case *ast.Ident, *ast.SelectorExpr:
+		path := exprToString(expr)
+		col, ok := t.colidx[path]
+		if !ok {
+			switch path {
+			case "true", "false":
+				return constant.MakeBool(path == "true"), nil
+			}
+			return nil, fmt.Errorf("unknown column %s", path)
+		}
+		return tableCellValue(t.cell(row, col)), nil
+
+	case *ast.UnaryExpr:
+		x, err := f.eval(expr.X, t, row)
+		if err != nil {
+			return nil, err
+		}
+		return constant.UnaryOp(expr.Op, x, 0), nil
+
+	case *ast.BinaryExpr:
+		x, err := f.eval(expr.X, t, row)
+		if err != nil {
+			return nil, err
+		}
+		y, err := f.eval(expr.Y, t, row)
+		if err != nil {
+			return nil, err
+		}
+		if !constantsCompatible(x, y) && expr.Op != token.SHL && expr.Op != token.SHR {
+			return nil, fmt.Errorf("mismatched operands %s %s %s", x, expr.Op, y)
+		}
+		switch expr.Op {
+		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
+			return constant.MakeBool(constant.Compare(x, expr.Op, y)), nil
+		case token.SHL, token.SHR:
+			s, ok := constant.Uint64Val(y)
+			if !ok {
+				return nil, errors.New("invalid shift count")
+			}
+			return constant.Shift(x, expr.Op, uint(s)), nil
+		default:
+			return constant.BinaryOp(x, expr.Op, y), nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported expression %s", exprToString(expr))
+}
+
+func constantsCompatible(x, y constant.Value) bool {
+	isnum := func(v constant.Value) bool {
+		switch v.Kind() {
+		case constant.Int, constant.Float, constant.Complex:
+			return true
+		}
+		return false
+	}
+	return x.Kind() == y.Kind() || (isnum(x) && isnum(y))
+}
+
+// tableCellValue converts the contents of a cell to a constant, cells that
+// are not numbers, booleans or quoted strings are treated as strings.
+func tableCellValue(s string) constant.Value {
+	switch {
+	case s == "true" || s == "false":
+		return constant.MakeBool(s == "true")
+	case strings.HasPrefix(s, "\""):
+		if u, err := strconv.Unquote(s); err == nil {
+			return constant.MakeString(u)
+		}
+	default:
+		for _, kind := range []token.Token{token.INT, token.FLOAT} {
+			if v := constant.MakeFromLiteral(s, kind, 0); v.Kind() != constant.Unknown {
+				return v
+			}
+		}
+	}
+	return constant.MakeString(s)
+}
+
+func exprToString(expr ast.Expr) string {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		return expr.Name
+	case *ast.SelectorExpr:
+		return exprToString(expr.X) + "." + expr.Sel.Name
+	default:
+		return fmt.Sprintf("%T", expr)
+	}
+}
+
+// tableViewer is the state of the detail viewer for slices of structs.
+type tableViewer struct {
+	t        *structTable
+	id       int
+	order    []int
+	sortCol  int
+	sortDesc bool
+	filterEd nucular.TextEditor
+	filter   *tableFilter
+	err      error
+}
+
+func newTableViewer() *tableViewer {
+	tv := &tableViewer{sortCol: -1}
+	tv.filterEd.Flags = nucular.EditSelectable | nucular.EditClipboard | nucular.EditSigEnter
+	return tv
+}
+
+// setRows replaces the contents of the table, keeping the current filter
+// and sort order.
+func (tv *tableViewer) setRows(elems []api.Variable) {
+	tv.t = newStructTable(elems)
+	if tv.sortCol >= len(tv.t.columns) {
+		tv.sortCol = -1
+	}
+	tv.refresh()
+}
+
+// appendRows adds rows loaded by loadMore.
+func (tv *tableViewer) appendRows(elems []api.Variable) {
+	tv.t.appendRows(elems)
+	tv.refresh()
+}
+
+// refresh recomputes the visible rows and their order.
+func (tv *tableViewer) refresh() {
+	tv.id++
+	tv.err = nil
+	tv.order = tv.order[:0]
+	for i := range tv.t.rows {
+		if tv.filter != nil {
+			ok, err := tv.filter.match(tv.t, i)
+			if err != nil {
+				tv.err = err
+				ok = true
+			}
+			if !ok {
+				continue
+			}
+		}
+		tv.order = append(tv.order, i)
+	}
+	if tv.sortCol >= 0 {
+		tv.t.sortRows(tv.order, tv.sortCol, tv.sortDesc)
+	}
+}
+
+func (dv *detailViewer) tableUpdate(w *nucular.Window) {
+	dv.mu.Lock()
+	defer dv.mu.Unlock()
+	tv := dv.table
+
+	w.Row(20).Static(100, 0, 100)
+	w.Label("Filter:", "LC")
+	active := tv.filterEd.Edit(w)
+	if w.ButtonText("Load more") {
+		dv.loadMore()
+	}
+	if active&nucular.EditCommitted != 0 {
+		tv.filter = nil
+		var err error
+		if s := strings.TrimSpace(string(tv.filterEd.Buffer)); s != "" {
+			tv.filter, err = compileTableFilter(s)
+		}
+		tv.refresh()
+		if err != nil {
+			tv.err = err
+		}
+	}
+	if tv.err != nil {
+		w.Row(20).Dynamic(1)
+		w.LabelColored(fmt.Sprintf("Filter error: %v", tv.err), "LC", errorColor)
+	}
+
+	w.Row(0).Dynamic(1)
+	if w := w.GroupBegin("table", 0); w != nil {
+		w.Row(posRowHeight).Static()
+		w.LayoutFitWidth(tv.id, 1)
+		w.Label("#", "LC")
+		for i, col := range tv.t.columns {
+			if i == tv.sortCol {
+				if tv.sortDesc {
+					col += " ▼"
+				} else {
+					col += " ▲"
+				}
+			}
+			w.LayoutFitWidth(tv.id, 1)
+			w.LabelColored(col, "LC", linkColor)
+			if w.Input().Mouse.AnyClickInRect(w.LastWidgetBounds) {
+				if tv.sortCol == i {
+					tv.sortDesc = !tv.sortDesc
+				} else {
+					tv.sortCol, tv.sortDesc = i, false
+				}
+				tv.refresh()
+			}
+		}
+
+		for _, row := range tv.order {
+			w.Row(posRowHeight).Static()
+			w.LayoutFitWidth(tv.id, 1)
+			w.Label(strconv.Itoa(row), "RC")
+			for col := range tv.t.columns {
+				w.LayoutFitWidth(tv.id, 1)
+				w.Label(tv.t.cell(row, col), "LC")
+				if cw := w.ContextualOpen(0, image.Point{}, w.LastWidgetBounds, nil); cw != nil {
+					cw.Row(20).Dynamic(1)
+					if cw.MenuItem(label.TA("Copy value", "LC")) {
+						clipboard.Set(tv.t.cell(row, col))
+					}
+					if cw.MenuItem(label.TA("Add element to variables panel", "LC")) {
+						addExpression(fmt.Sprintf("%s[%d]", dv.v.Expression, row))
+					}
+				}
+			}
+		}
+		w.GroupEnd()
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/rect"
+	nstyle "github.com/aarzilli/nucular/style"
+)
+
+const testScriptCommand = "test-script"
+
+// TestScript is the script executed by 'gdlv test-script', when it is set
+// gdlv runs without a GUI.
+var TestScript string
+
+// headlessWindow replaces the master window when gdlv runs without a GUI,
+// it only provides locking, everything that would be displayed is
+// discarded.
+type headlessWindow struct {
+	mu    sync.Mutex
+	style *nstyle.Style
+	input nucular.Input
+}
+
+func (w *headlessWindow) Main()                        {}
+func (w *headlessWindow) Changed()                     {}
+func (w *headlessWindow) Close()                       {}
+func (w *headlessWindow) OnClose(func())               {}
+func (w *headlessWindow) Style() *nstyle.Style         { return w.style }
+func (w *headlessWindow) SetStyle(s *nstyle.Style)     { w.style = s }
+func (w *headlessWindow) Input() *nucular.Input        { return &w.input }
+func (w *headlessWindow) Walk(fn nucular.WindowWalkFn) {}
+func (w *headlessWindow) Lock()                        { w.mu.Lock() }
+func (w *headlessWindow) Unlock()                      { w.mu.Unlock() }
+
+func (w *headlessWindow) PopupOpen(title string, flags nucular.WindowFlags, rect rect.Rect, scale bool, updateFn nucular.UpdateFn) {
+}
+
+// ResetWindows returns nil, callers must check that gdlv is not headless.
+func (w *headlessWindow) ResetWindows() *nucular.DockSplit {
+	return nil
+}
+
+// headless returns true if gdlv is running without a GUI.
+func headless() bool {
+	_, ok := wnd.(*headlessWindow)
+	return ok
+}
+
+// runScriptTests runs the tests defined in TestScript against the target
+// specified on the command line and returns the exit status of gdlv.
+// Between tests the target is restarted and all breakpoints are cleared so
+// that, when the target is a recording, every test sees the same execution.
+func runScriptTests() int {
+	wnd = &headlessWindow{style: nstyle.FromTheme(nstyle.DarkTheme, 1.0)}
+	cmds = DebugCommands()
+	curThread = -1
+	curGid = -1
+
+	BackendServer.connected = make(chan bool, 1)
+	go BackendServer.Start()
+	defer BackendServer.Close()
+	if !<-BackendServer.connected {
+		fmt.Fprintf(os.Stderr, "could not connect to the target\n")
+		return 1
+	}
+	defer func() {
+		client.Detach(!client.AttachedToExistingProcess())
+	}()
+
+	first := true
+	setup := func() error {
+		if first {
+			// the target was just started
+			first = false
+			return nil
+		}
+		return restartForTest()
+	}
+
+	failed, err := StarlarkEnv.RunTests(os.Stdout, TestScript, setup)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// restartForTest restarts the target, clears all breakpoints and continues
+// to the startup function.
+func restartForTest() error {
+	if _, err := client.RestartFrom("", false, nil, false); err != nil {
+		return fmt.Errorf("could not restart: %v", err)
+	}
+	bps, err := client.ListBreakpoints()
+	if err != nil {
+		return err
+	}
+	for _, bp := range bps {
+		if bp.ID > 0 {
+			if _, err := client.ClearBreakpoint(bp.ID); err != nil {
+				return err
+			}
+		}
+	}
+	continueToRuntimeMain()
+	refreshState(refreshToFrameZero, clearStop, nil)
+	return nil
+}
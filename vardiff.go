@@ -0,0 +1,433 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/parser"
+	"image"
+	"image/color"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aarzilli/nucular"
+	"github.com/aarzilli/nucular/clipboard"
+	"github.com/aarzilli/nucular/label"
+	"github.com/aarzilli/nucular/rect"
+
+	"github.com/aarzilli/gdlv/internal/dlvclient/service/api"
+	"github.com/aarzilli/gdlv/internal/prettyprint"
+)
+
+var (
+	diffAddedColor   = color.RGBA{0x55, 0xcc, 0x55, 0xff}
+	diffRemovedColor = color.RGBA{0xff, 0x55, 0x55, 0xff}
+	diffChangedColor = color.RGBA{0xdd, 0xaa, 0x00, 0xff}
+)
+
+type diffKind uint8
+
+const (
+	diffChanged diffKind = iota
+	diffAdded
+	diffRemoved
+)
+
+func (k diffKind) String() string {
+	switch k {
+	case diffAdded:
+		return "added"
+	case diffRemoved:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// variableDiff is a path where two variables differ.
+type variableDiff struct {
+	Path     string
+	Kind     diffKind
+	Old, New string
+}
+
+// diffVariables walks a and b in parallel and returns the list of paths
+// where they differ.
+func diffVariables(path string, a, b *api.Variable) []variableDiff {
+	var r []variableDiff
+	diffVariablesTo(&r, path, a, b)
+	return r
+}
+
+func diffVariablesTo(r *[]variableDiff, path string, a, b *api.Variable) {
+	changed := func(includeType bool) {
+		*r = append(*r, variableDiff{Path: path, Kind: diffChanged, Old: prettyprint.Singleline(a, includeType, false), New: prettyprint.Singleline(b, includeType, false)})
+	}
+
+	if a.Unreadable != "" || b.Unreadable != "" {
+		if a.Unreadable != b.Unreadable {
+			changed(false)
+		}
+		return
+	}
+
+	if a.Kind != b.Kind || a.Type != b.Type {
+		changed(true)
+		return
+	}
+
+	switch a.Kind {
+	case reflect.Struct:
+		bfields := make(map[string]*api.Variable, len(b.Children))
+		for i := range b.Children {
+			bfields[b.Children[i].Name] = &b.Children[i]
+		}
+		for i := range a.Children {
+			af := &a.Children[i]
+			if bf := bfields[af.Name]; bf != nil {
+				diffVariablesTo(r, path+"."+af.Name, af, bf)
+				delete(bfields, af.Name)
+			} else {
+				*r = append(*r, variableDiff{Path: path + "." + af.Name, Kind: diffRemoved, Old: prettyprint.Singleline(af, false, false)})
+			}
+		}
+		for i := range b.Children {
+			if bf := &b.Children[i]; bfields[bf.Name] != nil {
+				*r = append(*r, variableDiff{Path: path + "." + bf.Name, Kind: diffAdded, New: prettyprint.Singleline(bf, false, false)})
+			}
+		}
+
+	case reflect.Array, reflect.Slice:
+		n := len(a.Children)
+		if len(b.Children) < n {
+			n = len(b.Children)
+		}
+		for i := 0; i < n; i++ {
+			diffVariablesTo(r, fmt.Sprintf("%s[%d]", path, i), &a.Children[i], &b.Children[i])
+		}
+		for i := n; i < len(a.Children); i++ {
+			*r = append(*r, variableDiff{Path: fmt.Sprintf("%s[%d]", path, i), Kind: diffRemoved, Old: prettyprint.Singleline(&a.Children[i], false, false)})
+		}
+		for i := n; i < len(b.Children); i++ {
+			*r = append(*r, variableDiff{Path: fmt.Sprintf("%s[%d]", path, i), Kind: diffAdded, New: prettyprint.Singleline(&b.Children[i], false, false)})
+		}
+		if a.Len != b.Len && len(a.Children) == len(b.Children) {
+			*r = append(*r, variableDiff{Path: "len(" + path + ")", Kind: diffChanged, Old: strconv.FormatInt(a.Len, 10), New: strconv.FormatInt(b.Len, 10)})
+		}
+
+	case reflect.Map:
+		// children of maps are key, value pairs
+		bkeys := make(map[string]int, len(b.Children)/2)
+		for i := 0; i+1 < len(b.Children); i += 2 {
+			bkeys[prettyprint.Singleline(&b.Children[i], false, false)] = i
+		}
+		for i := 0; i+1 < len(a.Children); i += 2 {
+			key := prettyprint.Singleline(&a.Children[i], false, false)
+			keypath := path + "[" + key + "]"
+			if j, ok := bkeys[key]; ok {
+				diffVariablesTo(r, keypath, &a.Children[i+1], &b.Children[j+1])
+				delete(bkeys, key)
+			} else {
+				*r = append(*r, variableDiff{Path: keypath, Kind: diffRemoved, Old: prettyprint.Singleline(&a.Children[i+1], false, false)})
+			}
+		}
+		for i := 0; i+1 < len(b.Children); i += 2 {
+			key := prettyprint.Singleline(&b.Children[i], false, false)
+			if _, ok := bkeys[key]; ok {
+				*r = append(*r, variableDiff{Path: path + "[" + key + "]", Kind: diffAdded, New: prettyprint.Singleline(&b.Children[i+1], false, false)})
+			}
+		}
+
+	case reflect.Ptr:
+		if len(a.Children) == 1 && len(b.Children) == 1 && !a.Children[0].OnlyAddr && !b.Children[0].OnlyAddr && a.Children[0].Addr != 0 && b.Children[0].Addr != 0 {
+			// compare what the pointers point to, not their addresses
+			diffVariablesTo(r, path, &a.Children[0], &b.Children[0])
+		} else if prettyprint.Singleline(a, false, false) != prettyprint.Singleline(b, false, false) {
+			changed(false)
+		}
+
+	case reflect.Interface:
+		if len(a.Children) == 1 && len(b.Children) == 1 {
+			diffVariablesTo(r, path, &a.Children[0], &b.Children[0])
+		} else if len(a.Children) != len(b.Children) {
+			changed(true)
+		}
+
+	default:
+		if prettyprint.Singleline(a, false, false) != prettyprint.Singleline(b, false, false) {
+			changed(false)
+		}
+	}
+}
+
+// variableSnapshot is a saved copy of the value of an expression.
+type variableSnapshot struct {
+	Expr string
+	When time.Time
+	V    *api.Variable
+}
+
+var variableSnapshots []variableSnapshot
+
+func saveVariableSnapshot(expr string, v *api.Variable) int {
+	variableSnapshots = append(variableSnapshots, variableSnapshot{expr, time.Now(), v})
+	return len(variableSnapshots)
+}
+
+func (s *variableSnapshot) String() string {
+	return fmt.Sprintf("%s at %s", s.Expr, s.When.Format("15:04:05"))
+}
+
+// parseSnapshotRef parses a reference to a snapshot, #n.
+func parseSnapshotRef(s string) (int, bool) {
+	if len(s) < 2 || s[0] != '#' {
+		return 0, false
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
+
+// splitDiffArgs splits the arguments of the diff command in two
+// expressions.
+func splitDiffArgs(args string) (string, string, error) {
+	valid := func(s string) bool {
+		if _, ok := parseSnapshotRef(s); ok {
+			return true
+		}
+		return validScopedExpr(s)
+	}
+	a, b, ok := splitTwoExprs(args, valid, valid)
+	if !ok {
+		return "", "", errors.New("could not split arguments in two expressions")
+	}
+	return a, b, nil
+}
+
+// splitTwoExprs splits args in two expressions. Expressions can contain
+// spaces, the first split point where the first half is accepted by valid1
+// and the second half by valid2 is used.
+func splitTwoExprs(args string, valid1, valid2 func(string) bool) (string, string, bool) {
+	args = strings.TrimSpace(args)
+	for i, ch := range args {
+		if !unicode.IsSpace(ch) {
+			continue
+		}
+		a, b := strings.TrimSpace(args[:i]), strings.TrimSpace(args[i:])
+		if a != "" && b != "" && valid1(a) && valid2(b) {
+			return a, b, true
+		}
+	}
+	return "", "", false
+}
+
+// validScopedExpr returns true if s is a syntactically valid expression,
+// optionally preceded by a scope expression. Starlark expressions are
+// only accepted if they do not contain spaces.
+func validScopedExpr(s string) bool {
+	se := ParseScopedExpr(s)
+	if se.Kind == InvalidScopeExpr {
+		return false
+	}
+	if len(se.EvalExpr) > 0 && se.EvalExpr[0] == '$' {
+		return strings.IndexFunc(se.EvalExpr, unicode.IsSpace) < 0
+	}
+	_, err := parser.ParseExpr(se.EvalExpr)
+	return err == nil
+}
+
+// evalDiffOperand evaluates expr, which can be an expression or a
+// reference to a snapshot.
+func evalDiffOperand(expr string) (*api.Variable, error) {
+	if n, ok := parseSnapshotRef(expr); ok {
+		if n < 1 || n > len(variableSnapshots) {
+			return nil, fmt.Errorf("no snapshot %s", expr)
+		}
+		return variableSnapshots[n-1].V, nil
+	}
+	v := evalScopedExpr(replaceRegs(expr), getVariableLoadConfig())
+	if v.Unreadable != "" {
+		return nil, fmt.Errorf("could not evaluate %s: %s", expr, v.Unreadable)
+	}
+	return v, nil
+}
+
+func diffCommand(out io.Writer, args string) error {
+	if strings.HasPrefix(args, "-snapshot ") {
+		expr := strings.TrimSpace(args[len("-snapshot "):])
+		v, err := evalDiffOperand(expr)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "Saved snapshot #%d of %s\n", saveVariableSnapshot(expr, v), expr)
+		return nil
+	}
+	if strings.TrimSpace(args) == "-list" {
+		for i := range variableSnapshots {
+			fmt.Fprintf(out, "#%d\t%s\n", i+1, variableSnapshots[i].String())
+		}
+		return nil
+	}
+
+	expr1, expr2, err := splitDiffArgs(args)
+	if err != nil {
+		return err
+	}
+	return openDiff(expr1, expr2)
+}
+
+// diffRootName returns the name used as the root of the paths reported
+// when diffing expr, for snapshot references this is the expression the
+// snapshot was taken of.
+func diffRootName(expr string) string {
+	if n, ok := parseSnapshotRef(expr); ok && n >= 1 && n <= len(variableSnapshots) {
+		return variableSnapshots[n-1].Expr
+	}
+	return expr
+}
+
+func openDiff(expr1, expr2 string) error {
+	a, err := evalDiffOperand(expr1)
+	if err != nil {
+		return err
+	}
+	b, err := evalDiffOperand(expr2)
+	if err != nil {
+		return err
+	}
+	openDiffValues(expr1, expr2, a, b)
+	return nil
+}
+
+// openDiffValues opens a window showing the differences between a and b,
+// the values of expr1 and expr2.
+func openDiffValues(expr1, expr2 string, a, b *api.Variable) {
+	dw := &diffWindow{expr1: expr1, expr2: expr2, diffs: diffVariables(diffRootName(expr1), a, b)}
+	wnd.PopupOpen(fmt.Sprintf("Diff %s %s", expr1, expr2), popupFlags|nucular.WindowNonmodal|nucular.WindowScalable|nucular.WindowClosable, rect.Rect{100, 100, 600, 400}, true, dw.update)
+}
+
+type diffWindow struct {
+	expr1, expr2 string
+	diffs        []variableDiff
+}
+
+func (dw *diffWindow) update(w *nucular.Window) {
+	w.Row(20).Dynamic(1)
+	w.Label(fmt.Sprintf("%d differences between %s and %s", len(dw.diffs), dw.expr1, dw.expr2), "LC")
+
+	w.Row(0).Dynamic(1)
+	if w := w.GroupBegin("diff-list", 0); w != nil {
+		if len(dw.diffs) == 0 {
+			w.Row(posRowHeight).Dynamic(1)
+			w.Label("(no differences)", "LC")
+		}
+		for i := range dw.diffs {
+			d := &dw.diffs[i]
+			path := d.Path
+			if path == "" {
+				path = "(value)"
+			}
+			w.Row(posRowHeight).Static()
+			w.LayoutFitWidth(0, 100)
+			w.Label(path, "LT")
+			w.LayoutFitWidth(0, 1)
+			switch d.Kind {
+			case diffAdded:
+				w.LabelColored(d.Kind.String(), "LT", diffAddedColor)
+				w.LayoutFitWidth(0, 100)
+				w.Label(d.New, "LT")
+			case diffRemoved:
+				w.LabelColored(d.Kind.String(), "LT", diffRemovedColor)
+				w.LayoutFitWidth(0, 100)
+				w.Label(d.Old, "LT")
+			default:
+				w.LabelColored(d.Kind.String(), "LT", diffChangedColor)
+				w.LayoutFitWidth(0, 100)
+				w.Label(d.Old+" → "+d.New, "LT")
+			}
+			if cw := w.ContextualOpen(0, image.Point{}, w.LastWidgetBounds, nil); cw != nil {
+				cw.Row(20).Dynamic(1)
+				if cw.MenuItem(label.TA("Copy path", "LC")) {
+					clipboard.Set(d.Path)
+				}
+			}
+		}
+		w.GroupEnd()
+	}
+}
+
+// compareWindow asks for the expression or snapshot to compare a variable
+// with.
+type compareWindow struct {
+	v        *Variable
+	snapshot int
+	exprEd   nucular.TextEditor
+	status   string
+}
+
+func openCompareWith(mw nucular.MasterWindow, v *Variable) {
+	cw := &compareWindow{v: v}
+	cw.exprEd.Flags = nucular.EditSelectable | nucular.EditClipboard | nucular.EditSigEnter
+	mw.PopupOpen(fmt.Sprintf("Compare %s with", v.DisplayName), dynamicPopupFlags, rect.Rect{100, 100, 400, 700}, true, cw.update)
+}
+
+func (cw *compareWindow) update(w *nucular.Window) {
+	snapshots := []string{"(expression)"}
+	for i := range variableSnapshots {
+		snapshots = append(snapshots, fmt.Sprintf("#%d %s", i+1, variableSnapshots[i].String()))
+	}
+
+	w.Row(20).Static(100, 0)
+	w.Label("Snapshot:", "LC")
+	cw.snapshot = w.ComboSimple(snapshots, cw.snapshot, 20)
+	w.Row(20).Static(100, 0)
+	w.Label("Expression:", "LC")
+	active := cw.exprEd.Edit(w)&nucular.EditCommitted != 0
+
+	if cw.status != "" {
+		w.Row(20).Dynamic(1)
+		w.Label(cw.status, "LC")
+	}
+
+	w.Row(20).Static(0, 100, 100)
+	w.Spacing(1)
+	if w.ButtonText("Cancel") {
+		w.Close()
+	}
+	if w.ButtonText("Compare") || active {
+		// the current value is the one already loaded, including children
+		// loaded after the first load
+		expr, cur := cw.v.Expression, cw.v.apiVariable()
+		if cw.snapshot > 0 {
+			// snapshots are older than the current value and go on the left side
+			a, err := evalDiffOperand(fmt.Sprintf("#%d", cw.snapshot))
+			if err != nil {
+				cw.status = fmt.Sprintf("Error: %v", err)
+				return
+			}
+			w.Close()
+			openDiffValues(fmt.Sprintf("#%d", cw.snapshot), expr, a, cur)
+			return
+		}
+		expr2 := strings.TrimSpace(string(cw.exprEd.Buffer))
+		if expr2 == "" {
+			cw.status = "Error: no expression"
+			return
+		}
+		w.Close()
+		go func() {
+			b, err := evalDiffOperand(expr2)
+			if err != nil {
+				out := editorWriter{true}
+				fmt.Fprintf(&out, "Error: %v\n", err)
+				return
+			}
+			openDiffValues(expr, expr2, cur, b)
+		}()
+	}
+}
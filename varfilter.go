@@ -0,0 +1,115 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// variableFilter matches variable names, paths (for example a.b[2].c) and
+// values against a substring or a regular expression.
+type variableFilter struct {
+	needle string
+	rx     *regexp.Regexp
+
+	visible map[*Variable]bool // variables that match, have a matching ancestor or a matching descendant
+	open    map[*Variable]bool // variables that have a matching descendant
+	expand  bool               // force open variables with matching descendants
+
+	incomplete *Variable // a variable with children that were not loaded yet
+}
+
+// maxFilterLoads is the number of incomplete variables that will be loaded
+// automatically, looking for matches, every time the filter changes.
+const maxFilterLoads = 10
+
+func newVariableFilter(needle string, isRegexp bool) (*variableFilter, error) {
+	f := &variableFilter{needle: needle, visible: map[*Variable]bool{}, open: map[*Variable]bool{}}
+	if isRegexp {
+		rx, err := regexp.Compile(needle)
+		if err != nil {
+			return nil, err
+		}
+		f.rx = rx
+	}
+	return f, nil
+}
+
+func (f *variableFilter) match(s string) bool {
+	if f.rx != nil {
+		return f.rx.MatchString(s)
+	}
+	return strings.Index(s, f.needle) >= 0
+}
+
+// visit determines which variables in the tree rooted at v should be
+// displayed, returns true if v or one of its descendants matches.
+func (f *variableFilter) visit(v *Variable, path string, ancestorMatched bool, depth int) bool {
+	if v == nil {
+		return false
+	}
+	// once an ancestor matched the path of its descendants will too, matching
+	// them again would expand every descendant.
+	self := f.match(v.DisplayName) || (!ancestorMatched && f.match(path)) || (v.Value != "" && f.match(v.Value))
+	descendant := false
+	for _, child := range v.Children {
+		if child != nil && f.visit(child, variableChildPath(v, path, child), ancestorMatched || self, depth+1) {
+			descendant = true
+		}
+	}
+	if !self && !descendant && f.incomplete == nil && depth < 10 && variableIncomplete(v) {
+		f.incomplete = v
+	}
+	f.open[v] = descendant
+	f.visible[v] = ancestorMatched || self || descendant
+	return self || descendant
+}
+
+// variableChildPath returns the path of child, a child of v with path
+// path.
+func variableChildPath(v *Variable, path string, child *Variable) string {
+	switch v.Kind {
+	case reflect.Ptr, reflect.Interface:
+		return path
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return path + child.DisplayName
+	default:
+		return path + "." + child.DisplayName
+	}
+}
+
+// variableIncomplete returns true if some of the children of v were not
+// loaded and could contain a match.
+func variableIncomplete(v *Variable) bool {
+	if v.Unreadable != "" || v.loading || v.Addr == 0 {
+		return false
+	}
+	switch v.Kind {
+	case reflect.Struct:
+		return len(v.Children) == 0 && v.Len > 0
+	case reflect.Ptr:
+		return len(v.Children) == 1 && v.Children[0].OnlyAddr
+	case reflect.Map:
+		return len(v.Children)/2 != int(v.Len)
+	}
+	return false
+}
+
+// loadIncomplete starts loading the children of one of the variables
+// whose children were not loaded, returns false if no load was started.
+// Must be called with additionalLoadMu held.
+func (f *variableFilter) loadIncomplete() bool {
+	v := f.incomplete
+	if v == nil || additionalLoadRunning {
+		return false
+	}
+	switch v.Kind {
+	case reflect.Struct:
+		loadMoreStruct(v)
+	case reflect.Ptr:
+		loadMoreStruct(v.Children[0])
+	case reflect.Map:
+		loadMoreMap(v)
+	}
+	return true
+}